@@ -2,7 +2,6 @@ package log
 
 import (
 	"context"
-	"os"
 )
 
 type (
@@ -27,6 +26,25 @@ func (lv Level) String() string {
 	return "UNK"
 }
 
+// name returns the lower-case full name of level, it's used by sinks those
+// prefer readable level labels to the abbreviation returned by String.
+func (lv Level) name() string {
+	switch lv {
+	case LevelFatal:
+		return "fatal"
+	case LevelError:
+		return "error"
+	case LevelWarning:
+		return "warning"
+	case LevelInfo:
+		return "info"
+	case LevelDebug:
+		return "debug"
+	}
+
+	return "unknown"
+}
+
 func (lv Level) Color() string {
 	switch lv {
 	case LevelFatal:
@@ -66,13 +84,13 @@ func init() {
 // Fatal .
 func Fatal(args ...interface{}) {
 	builtin.Fatal(args...)
-	os.Exit(1)
+	exit(1)
 }
 
 // Fatalf .
 func Fatalf(format string, args ...interface{}) {
 	builtin.Fatalf(format, args...)
-	os.Exit(1)
+	exit(1)
 }
 
 // Error .
@@ -130,11 +148,26 @@ func WithContext(ctx context.Context) *entry {
 	return builtin.WithContext(ctx)
 }
 
+// BeginScope begins a scope of builtin logger, see Logger.BeginScope.
+func BeginScope(ctx context.Context) (context.Context, func(failed bool)) {
+	return builtin.BeginScope(ctx)
+}
+
 // SetLogLevel .
 func SetLogLevel(level Level) {
 	builtin.SetLogLevel(level)
 }
 
+// Sync flushes the writers and sinks of builtin logger.
+func Sync() error {
+	return builtin.Sync()
+}
+
+// Close closes the writers and sinks of builtin logger.
+func Close() error {
+	return builtin.Close()
+}
+
 func SetCallerReporter(b bool) {
 	builtin.SetCallerReporter(b)
 }
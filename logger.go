@@ -10,23 +10,21 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"path"
 	"path/filepath"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 )
 
-var (
-	lastSplitTimestamp time.Time = time.Now() // last ts when split the file
-)
-
 type Logger struct {
 	opt *options
 
-	entryPool sync.Pool // entry pool
+	entryPool   sync.Pool     // entry pool
+	errCounters errorCounters // counters of errors occurred while outputting
+	closed      int32         // set to 1 by Close
+	hooks       hookSet       // hooks fired by level
+	metrics     loggerMetrics // counters of entries and bytes
 }
 
 // NewLogger using os.Stdout and LevelDebug to print log
@@ -37,11 +35,19 @@ func NewLogger(opts ...LoggerOption) (*Logger, error) {
 	return newLoggerWithOptions(in...)
 }
 
+// loggerBinder is implemented by processors, writers, sinks and hooks which
+// need to log or report errors by the Logger they are attached to, bind is
+// called after Logger was created or the hook was added.
+type loggerBinder interface {
+	bind(l *Logger)
+}
+
 func newLoggerWithOptions(opts ...LoggerOption) (*Logger, error) {
 	dst := new(options)
 
 	for _, opt := range opts {
 		if err := opt(dst); err != nil {
+			dst.releaseFiles()
 			return nil, errors.Wrap(err, "failed to apply option")
 		}
 	}
@@ -54,6 +60,27 @@ func newLoggerWithOptions(opts ...LoggerOption) (*Logger, error) {
 			},
 		},
 	}
+	l.hooks.add(dst.hooks...)
+	for _, h := range dst.hooks {
+		if b, ok := h.(loggerBinder); ok {
+			b.bind(&l)
+		}
+	}
+	for _, p := range dst.processors {
+		if b, ok := p.(loggerBinder); ok {
+			b.bind(&l)
+		}
+	}
+	for _, w := range dst.outputs {
+		if b, ok := w.(loggerBinder); ok {
+			b.bind(&l)
+		}
+	}
+	for _, s := range dst.sinks {
+		if b, ok := s.(loggerBinder); ok {
+			b.bind(&l)
+		}
+	}
 
 	return &l, nil
 }
@@ -63,7 +90,7 @@ func (l *Logger) Fatal(args ...interface{}) {
 	e.Fatal(args...)
 	l.releaseEntry(e)
 
-	os.Exit(1)
+	exit(1)
 }
 
 func (l *Logger) Fatalf(format string, args ...interface{}) {
@@ -71,7 +98,7 @@ func (l *Logger) Fatalf(format string, args ...interface{}) {
 	e.Fatalf(format, args...)
 	l.releaseEntry(e)
 
-	os.Exit(1)
+	exit(1)
 }
 
 func (l *Logger) Error(args ...interface{}) {
@@ -138,6 +165,8 @@ func (l *Logger) newEntry() *entry {
 			l.opt.formatTimeLayout,
 		)
 		e.ctxParser = l.opt.ctxParser
+		e.sinks = l.opt.sinks
+		e.recorder = l.opt.recorder
 		// FIXED(@yeqown): reuse entry incorrectly.
 		return e
 	}
@@ -203,36 +232,8 @@ func open(file string) (fd *os.File, err error) {
 	return fd, nil
 }
 
-// rename file
-func rename(dir, filename string) error {
-	return os.Rename(
-		assembleFilename(dir, filename, true),                  // old name
-		assembleFilename(dir, rotateFilename(filename), false), // new name
-	)
-}
-
-// assembleFilename, if filename if not end of '.log' then append '.log' to filename
-//
-// example:
-// filename = "app"
-// rename to: "app.log"
-func assembleFilename(dir, filename string, autoSuffix bool) string {
-	if autoSuffix && !strings.HasSuffix(filename, ".log") {
-		filename = fmt.Sprintf("%s.log", filename)
-	}
-
-	return path.Join(dir, filename)
-}
-
-// rotateFilename name the old `{filename}` into `{filename}-{date}`
-// rotateFilename(`app.log`) => `app.log-20200730`
-func rotateFilename(filename string) string {
-	date := lastSplitTimestamp.Format("20060102")
-	return fmt.Sprintf("%s-%s", filename, date)
-}
-
-// shouldSplitByTime judge by current time and lastLogFileDate
-// if now is another day from lastLogFileData, then split the log file
-func shouldSplitByTime(now time.Time) bool {
-	return now.Day() != lastSplitTimestamp.Day()
+// rotateFilenameAt name the old `{filename}` into `{filename}-{date}`
+// rotateFilenameAt(`app.log`, at) => `app.log-20200730`
+func rotateFilenameAt(filename string, at time.Time) string {
+	return fmt.Sprintf("%s-%s", filename, at.Format("20060102"))
 }
@@ -0,0 +1,273 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	_defaultAggregatorMaxGroups = 1000
+	_defaultAggregatorTopK      = 10
+)
+
+var (
+	_templateUUID   = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	_templateHex    = regexp.MustCompile(`0[xX][0-9a-fA-F]+`)
+	_templateQuoted = regexp.MustCompile(`"[^"]*"|'[^']*'`)
+	_templateNumber = regexp.MustCompile(`\d+(\.\d+)?`)
+)
+
+// messageTemplate replaces the variable parts of msg, such as numbers, ids
+// and quoted strings with placeholders, so that errors of the same kind
+// share one template.
+func messageTemplate(msg string) string {
+	msg = _templateUUID.ReplaceAllString(msg, "<uuid>")
+	msg = _templateHex.ReplaceAllString(msg, "<hex>")
+	msg = _templateQuoted.ReplaceAllString(msg, "<str>")
+	return _templateNumber.ReplaceAllString(msg, "<n>")
+}
+
+// ErrorGroup is the aggregated errors of one fingerprint.
+type ErrorGroup struct {
+	Fingerprint string    `json:"fingerprint"`
+	Template    string    `json:"template"`
+	Caller      string    `json:"caller,omitempty"`
+	Sample      string    `json:"sample"` // the first message of the group
+	Count       uint64    `json:"count"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// AggregatorOption to apply single function into `ao`.
+type AggregatorOption func(ao *aggregatorOptions) error
+
+type aggregatorOptions struct {
+	maxGroups int
+}
+
+// WithAggregatorMaxGroups sets the max count of groups to bound memory, the
+// group with the least count would be evicted if exceeded.
+func WithAggregatorMaxGroups(n int) AggregatorOption {
+	return func(ao *aggregatorOptions) error {
+		if n <= 0 {
+			return errors.Errorf("WithAggregatorMaxGroups: n must be positive, got %d", n)
+		}
+		ao.maxGroups = n
+		return nil
+	}
+}
+
+// ErrorAggregator is a Hook which fingerprints Error and Fatal entries by
+// message template and caller, and counts them, so that the most frequent
+// errors could be known without a log backend. The report could be logged
+// periodically by ReportTo, or served by ServeHTTP.
+type ErrorAggregator struct {
+	opt *aggregatorOptions
+
+	mu      sync.Mutex
+	groups  map[string]*ErrorGroup
+	evicted uint64
+}
+
+var (
+	_ Hook         = &ErrorAggregator{}
+	_ http.Handler = &ErrorAggregator{}
+)
+
+// NewErrorAggregator creates an ErrorAggregator, it should be added by WithHooks.
+func NewErrorAggregator(opts ...AggregatorOption) (*ErrorAggregator, error) {
+	ao := &aggregatorOptions{maxGroups: _defaultAggregatorMaxGroups}
+	for _, opt := range opts {
+		if err := opt(ao); err != nil {
+			return nil, errors.Wrap(err, "failed to apply option")
+		}
+	}
+
+	return &ErrorAggregator{
+		opt:    ao,
+		groups: make(map[string]*ErrorGroup, 16),
+	}, nil
+}
+
+// Levels returns Error and Fatal.
+func (a *ErrorAggregator) Levels() []Level {
+	return []Level{LevelError, LevelFatal}
+}
+
+// fingerprintEntry returns the fingerprint of e which is made up of its
+// message template and caller, the template and caller are returned too.
+func fingerprintEntry(e *Entry) (fingerprint, template, caller string) {
+	template = messageTemplate(e.Message)
+	if e.Caller != nil {
+		caller = e.Caller.File + ":" + strconv.Itoa(e.Caller.Line)
+	}
+
+	h := fnv.New64a()
+	_, _ = io.WriteString(h, template)
+	_, _ = h.Write([]byte{0})
+	_, _ = io.WriteString(h, caller)
+
+	return strconv.FormatUint(h.Sum64(), 16), template, caller
+}
+
+// Fire counts e into its group.
+func (a *ErrorAggregator) Fire(e *Entry) error {
+	fingerprint, template, caller := fingerprintEntry(e)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if g, ok := a.groups[fingerprint]; ok {
+		g.Count++
+		if e.Time.After(g.LastSeen) {
+			g.LastSeen = e.Time
+		}
+		return nil
+	}
+
+	if len(a.groups) >= a.opt.maxGroups {
+		a.evictLocked()
+	}
+	a.groups[fingerprint] = &ErrorGroup{
+		Fingerprint: fingerprint,
+		Template:    template,
+		Caller:      caller,
+		Sample:      e.Message,
+		Count:       1,
+		FirstSeen:   e.Time,
+		LastSeen:    e.Time,
+	}
+
+	return nil
+}
+
+// evictLocked removes the group with the least count, the least recently
+// seen one if there are several.
+func (a *ErrorAggregator) evictLocked() {
+	var victim *ErrorGroup
+	for _, g := range a.groups {
+		if victim == nil || g.Count < victim.Count ||
+			(g.Count == victim.Count && g.LastSeen.Before(victim.LastSeen)) {
+			victim = g
+		}
+	}
+	if victim != nil {
+		delete(a.groups, victim.Fingerprint)
+		atomic.AddUint64(&a.evicted, 1)
+	}
+}
+
+// TopK returns the k most frequent groups, k <= 0 means all.
+func (a *ErrorAggregator) TopK(k int) []ErrorGroup {
+	a.mu.Lock()
+	groups := make([]ErrorGroup, 0, len(a.groups))
+	for _, g := range a.groups {
+		groups = append(groups, *g)
+	}
+	a.mu.Unlock()
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].LastSeen.After(groups[j].LastSeen)
+	})
+	if k > 0 && len(groups) > k {
+		groups = groups[:k]
+	}
+
+	return groups
+}
+
+// Evicted returns the count of groups those have been evicted.
+func (a *ErrorAggregator) Evicted() uint64 {
+	return atomic.LoadUint64(&a.evicted)
+}
+
+// Reset removes all groups.
+func (a *ErrorAggregator) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.groups = make(map[string]*ErrorGroup, 16)
+}
+
+// ReportTo logs the top k groups into l every interval as Warning entries,
+// so they would not be aggregated again. The reports bypass the level and
+// processors of l, so they would be neither filtered nor sampled. The
+// returned stop function stops reporting. Nothing is reported if interval is
+// not positive.
+func (a *ErrorAggregator) ReportTo(l *Logger, interval time.Duration, k int) (stop func()) {
+	if interval <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				for i, g := range a.TopK(k) {
+					l.logDirect(LevelWarning, Fields{
+						"fingerprint": g.Fingerprint,
+						"caller":      g.Caller,
+						"count":       g.Count,
+						"first_seen":  g.FirstSeen.Format(time.RFC3339),
+						"last_seen":   g.LastSeen.Format(time.RFC3339),
+					}, fmt.Sprintf("top error #%d: %s", i+1, g.Template))
+				}
+			}
+		}
+	}()
+
+	var closed int32
+	return func() {
+		if atomic.CompareAndSwapInt32(&closed, 0, 1) {
+			close(done)
+		}
+	}
+}
+
+// ServeHTTP serves the top k groups in text format, or in JSON if the query
+// `format=json` is specified, k is set by the query `k`, 10 by default.
+func (a *ErrorAggregator) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	k := _defaultAggregatorTopK
+	if v := req.URL.Query().Get("k"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "invalid k: "+v, http.StatusBadRequest)
+			return
+		}
+		k = n
+	}
+	groups := a.TopK(k)
+
+	if req.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(groups)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = fmt.Fprintf(w, "==== top %d errors of %d evicted ====\n", len(groups), a.Evicted())
+	for _, g := range groups {
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n",
+			g.Count, g.FirstSeen.Format(time.RFC3339), g.LastSeen.Format(time.RFC3339), g.Caller, g.Template)
+	}
+}
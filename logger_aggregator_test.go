@@ -0,0 +1,191 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_messageTemplate(t *testing.T) {
+	assert.Equal(t, "user <n> not found", messageTemplate("user 42 not found"))
+	assert.Equal(t, "order <uuid> failed at <hex>",
+		messageTemplate("order 123e4567-e89b-12d3-a456-426614174000 failed at 0xdeadBEEF"))
+	assert.Equal(t, "open <str>: timeout after <n>s", messageTemplate(`open "/tmp/a 1": timeout after 1.5s`))
+}
+
+func Test_ErrorAggregator(t *testing.T) {
+	agg, err := NewErrorAggregator()
+	require.NoError(t, err)
+	l, err := NewLogger(WithCustomWriter(&bytes.Buffer{}), WithHooks(agg))
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		l.Errorf("user %d not found", i)
+	}
+	l.Error("db down")
+	l.Warn("not aggregated")
+
+	top := agg.TopK(0)
+	require.Len(t, top, 2)
+	assert.Equal(t, "user <n> not found", top[0].Template)
+	assert.Equal(t, "user 0 not found", top[0].Sample)
+	assert.Equal(t, uint64(3), top[0].Count)
+	assert.False(t, top[0].LastSeen.Before(top[0].FirstSeen))
+	assert.Equal(t, "db down", top[1].Template)
+
+	assert.Len(t, agg.TopK(1), 1)
+	agg.Reset()
+	assert.Empty(t, agg.TopK(0))
+}
+
+func Test_ErrorAggregator_caller(t *testing.T) {
+	agg, err := NewErrorAggregator()
+	require.NoError(t, err)
+
+	now := time.Now()
+	a := &runtime.Frame{File: "a.go", Line: 1}
+	b := &runtime.Frame{File: "b.go", Line: 2}
+	require.NoError(t, agg.Fire(&Entry{Level: LevelError, Message: "failed", Caller: a, Time: now}))
+	require.NoError(t, agg.Fire(&Entry{Level: LevelError, Message: "failed", Caller: b, Time: now}))
+	require.NoError(t, agg.Fire(&Entry{Level: LevelError, Message: "failed", Caller: a, Time: now.Add(time.Second)}))
+
+	top := agg.TopK(0)
+	require.Len(t, top, 2)
+	assert.Equal(t, "a.go:1", top[0].Caller)
+	assert.Equal(t, uint64(2), top[0].Count)
+	assert.Equal(t, now.Add(time.Second), top[0].LastSeen)
+	assert.Equal(t, now, top[0].FirstSeen)
+}
+
+func Test_ErrorAggregator_bounded(t *testing.T) {
+	agg, err := NewErrorAggregator(WithAggregatorMaxGroups(2))
+	require.NoError(t, err)
+
+	now := time.Now()
+	fire := func(msg string) {
+		require.NoError(t, agg.Fire(&Entry{Level: LevelError, Message: msg, Time: now}))
+	}
+	fire("a")
+	fire("a")
+	fire("b")
+	fire("c") // evicts b which has the least count.
+
+	top := agg.TopK(0)
+	require.Len(t, top, 2)
+	assert.Equal(t, "a", top[0].Template)
+	assert.Equal(t, "c", top[1].Template)
+	assert.Equal(t, uint64(1), agg.Evicted())
+}
+
+func Test_ErrorAggregator_ServeHTTP(t *testing.T) {
+	agg, err := NewErrorAggregator()
+	require.NoError(t, err)
+	require.NoError(t, agg.Fire(&Entry{Level: LevelError, Message: "id 1 failed", Time: time.Now()}))
+
+	rec := httptest.NewRecorder()
+	agg.ServeHTTP(rec, httptest.NewRequest("GET", "/errors", nil))
+	assert.Contains(t, rec.Body.String(), "1\t")
+	assert.Contains(t, rec.Body.String(), "id <n> failed")
+
+	rec = httptest.NewRecorder()
+	agg.ServeHTTP(rec, httptest.NewRequest("GET", "/errors?format=json&k=5", nil))
+	var groups []ErrorGroup
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &groups))
+	require.Len(t, groups, 1)
+	assert.Equal(t, "id 1 failed", groups[0].Sample)
+
+	rec = httptest.NewRecorder()
+	agg.ServeHTTP(rec, httptest.NewRequest("GET", "/errors?k=x", nil))
+	assert.Equal(t, 400, rec.Code)
+}
+
+func Test_ErrorAggregator_ReportTo(t *testing.T) {
+	agg, err := NewErrorAggregator()
+	require.NoError(t, err)
+
+	var (
+		mu       sync.Mutex
+		messages []string
+	)
+	l, err := NewLogger(WithCustomWriter(&bytes.Buffer{}), WithHooks(agg), WithSinks(SinkFunc(func(e *Entry) error {
+		mu.Lock()
+		defer mu.Unlock()
+		messages = append(messages, e.Message)
+		return nil
+	})))
+	require.NoError(t, err)
+	l.Error("boom 1")
+
+	stop := agg.ReportTo(l, 10*time.Millisecond, 3)
+	defer stop()
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, msg := range messages {
+			if strings.HasPrefix(msg, "top error #1: boom <n>") {
+				return true
+			}
+		}
+		return false
+	}, time.Second, 5*time.Millisecond)
+	stop()
+
+	// reports are not aggregated.
+	assert.Len(t, agg.TopK(0), 1)
+}
+
+func Test_ErrorAggregator_ReportTo_invalidInterval(t *testing.T) {
+	agg, err := NewErrorAggregator()
+	require.NoError(t, err)
+	l, err := NewLogger(WithCustomWriter(&bytes.Buffer{}))
+	require.NoError(t, err)
+
+	assert.NotPanics(t, func() {
+		agg.ReportTo(l, 0, 3)()
+		agg.ReportTo(l, -time.Second, 3)()
+	})
+}
+
+func Test_ErrorAggregator_ReportTo_level(t *testing.T) {
+	agg, err := NewErrorAggregator()
+	require.NoError(t, err)
+
+	var (
+		mu       sync.Mutex
+		messages []string
+	)
+	l, err := NewLogger(
+		WithCustomWriter(&bytes.Buffer{}),
+		WithLevel(LevelError),
+		WithHooks(agg),
+		WithSinks(SinkFunc(func(e *Entry) error {
+			mu.Lock()
+			defer mu.Unlock()
+			messages = append(messages, e.Message)
+			return nil
+		})),
+	)
+	require.NoError(t, err)
+	l.Error("boom 1")
+
+	stop := agg.ReportTo(l, 10*time.Millisecond, 3)
+	defer stop()
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, msg := range messages {
+			if strings.HasPrefix(msg, "top error #1: boom <n>") {
+				return true
+			}
+		}
+		return false
+	}, time.Second, 5*time.Millisecond, "reported though the level is Error")
+}
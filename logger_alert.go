@@ -0,0 +1,349 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	_defaultAlertCooldown        = 5 * time.Minute
+	_defaultAlertBatchSize       = 20
+	_defaultAlertBatchWait       = 2 * time.Second
+	_defaultAlertTimeout         = 5 * time.Second
+	_defaultAlertMaxFingerprints = 1000
+
+	// _defaultAlertTemplate is accepted by Slack, Mattermost and Rocket.Chat
+	// incoming webhooks.
+	_defaultAlertTemplate = `{"text": {{ json .Text }}}`
+)
+
+// Alert is the data to render the template of AlertHook, it contains the
+// entries of one burst.
+type Alert struct {
+	Entries []AlertEntry
+	// Text is the summary of entries, one line per entry.
+	Text string
+}
+
+// AlertEntry is an entry to be alerted.
+type AlertEntry struct {
+	*Entry
+
+	Fingerprint string
+	// Suppressed is the count of entries of the same fingerprint those have
+	// been suppressed by cooldown since the last alert.
+	Suppressed uint64
+}
+
+// line formats ae as a line of Alert.Text.
+func (ae AlertEntry) line() string {
+	var sb strings.Builder
+	sb.WriteString("[" + ae.Level.String() + "] " + ae.Message)
+	if ae.Caller != nil {
+		sb.WriteString(" (" + ae.Caller.File + ":" + strconv.Itoa(ae.Caller.Line) + ")")
+	}
+	if ae.Suppressed != 0 {
+		sb.WriteString(" +" + strconv.FormatUint(ae.Suppressed, 10) + " suppressed")
+	}
+
+	return sb.String()
+}
+
+// AlertOption to apply single function into `ao`.
+type AlertOption func(ao *alertOptions) error
+
+type alertOptions struct {
+	lv        Level
+	tmpl      *template.Template
+	cooldown  time.Duration
+	batchSize int
+	batchWait time.Duration
+	client    *http.Client
+	retry     retryPolicy
+}
+
+// WithAlertLevel sets the least severe level to be alerted, LevelError by default.
+func WithAlertLevel(lv Level) AlertOption {
+	return func(ao *alertOptions) error {
+		if lv > LevelDebug {
+			return errors.Errorf("WithAlertLevel: unknown level %d", lv)
+		}
+		ao.lv = lv
+		return nil
+	}
+}
+
+// WithAlertTemplate sets the text/template which renders Alert into the
+// JSON body of webhook request, `json` function is provided to quote values,
+// such as `{"text": {{ json .Text }}}` which is the default.
+func WithAlertTemplate(text string) AlertOption {
+	return func(ao *alertOptions) error {
+		tmpl, err := parseAlertTemplate(text)
+		if err != nil {
+			return errors.Wrap(err, "WithAlertTemplate")
+		}
+		ao.tmpl = tmpl
+		return nil
+	}
+}
+
+// WithAlertCooldown sets the duration in which the entries of the same
+// fingerprint (see ErrorAggregator) would be alerted only once, the
+// suppressed ones are counted into the next alert. 0 means no cooldown.
+func WithAlertCooldown(d time.Duration) AlertOption {
+	return func(ao *alertOptions) error {
+		if d < 0 {
+			return errors.Errorf("WithAlertCooldown: negative duration %s", d)
+		}
+		ao.cooldown = d
+		return nil
+	}
+}
+
+// WithAlertBatch sets max entries count in one alert and the max duration
+// an entry would wait, so that a burst of entries is sent as one message.
+func WithAlertBatch(size int, wait time.Duration) AlertOption {
+	return func(ao *alertOptions) error {
+		ao.batchSize = size
+		ao.batchWait = wait
+		return nil
+	}
+}
+
+// WithAlertTimeout sets the timeout of webhook request.
+func WithAlertTimeout(d time.Duration) AlertOption {
+	return func(ao *alertOptions) error {
+		if d <= 0 {
+			return errors.Errorf("WithAlertTimeout: timeout must be positive, got %s", d)
+		}
+		ao.client = &http.Client{Timeout: d}
+		return nil
+	}
+}
+
+// WithAlertRetry sets max retry times and the first backoff duration of
+// failed webhook request, backoff would be doubled after every failure.
+func WithAlertRetry(max int, backoff time.Duration) AlertOption {
+	return func(ao *alertOptions) error {
+		ao.retry.max = max
+		ao.retry.backoff = backoff
+		return nil
+	}
+}
+
+// alertCooldown is the cooldown state of a fingerprint.
+type alertCooldown struct {
+	last       time.Time
+	suppressed uint64
+}
+
+// AlertHook is a Hook which posts Error and Fatal entries to a webhook, such
+// as a chat channel. Fire only queues entries, they are rendered and posted
+// in another goroutine, so a slow webhook never blocks logging; entries would
+// be dropped with a hook error if the queue is full. Failed requests are
+// retried, and reported to the ErrorHandler of the Logger with ErrorKindHook.
+type AlertHook struct {
+	opt     *alertOptions
+	url     string
+	header  http.Header
+	batcher *batcher
+
+	mu        sync.Mutex
+	cooldowns map[string]*alertCooldown // by fingerprint
+	logger    *Logger                   // reports failures, guarded by mu
+}
+
+var (
+	_ Hook         = &AlertHook{}
+	_ loggerBinder = &AlertHook{}
+)
+
+// NewAlertHook creates an AlertHook posts to url, it should be added by WithHooks.
+func NewAlertHook(url string, opts ...AlertOption) (*AlertHook, error) {
+	if url == "" {
+		return nil, errors.New("NewAlertHook: empty url")
+	}
+
+	tmpl, _ := parseAlertTemplate(_defaultAlertTemplate)
+	ao := &alertOptions{
+		lv:        LevelError,
+		tmpl:      tmpl,
+		cooldown:  _defaultAlertCooldown,
+		batchSize: _defaultAlertBatchSize,
+		batchWait: _defaultAlertBatchWait,
+		client:    &http.Client{Timeout: _defaultAlertTimeout},
+		retry:     defaultRetryPolicy(),
+	}
+	for _, opt := range opts {
+		if err := opt(ao); err != nil {
+			return nil, errors.Wrap(err, "failed to apply option")
+		}
+	}
+
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+
+	h := &AlertHook{
+		opt:       ao,
+		url:       url,
+		header:    header,
+		cooldowns: make(map[string]*alertCooldown, 16),
+	}
+	h.batcher = newBatcher(ao.batchSize, ao.batchWait, h.post)
+
+	return h, nil
+}
+
+func parseAlertTemplate(text string) (*template.Template, error) {
+	return template.New("alert").
+		Funcs(template.FuncMap{"json": alertJSON}).
+		Option("missingkey=error").
+		Parse(text)
+}
+
+// alertJSON quotes v as JSON value.
+func alertJSON(v interface{}) (string, error) {
+	if err, ok := v.(error); ok {
+		v = err.Error()
+	}
+	if fields, ok := v.(Fields); ok {
+		m := make(map[string]interface{}, len(fields))
+		copyFields(m, fields)
+		return string(marshalJSON(m)), nil
+	}
+
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// bind attaches h to l, failures of posting are reported to l.
+func (h *AlertHook) bind(l *Logger) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.logger == nil {
+		h.logger = l
+	}
+}
+
+// Levels returns the levels those are equal to or more severe than the level
+// of WithAlertLevel.
+func (h *AlertHook) Levels() []Level {
+	return append([]Level(nil), AllLevels[:h.opt.lv+1]...)
+}
+
+// Fire queues e unless its fingerprint is in cooldown.
+func (h *AlertHook) Fire(e *Entry) error {
+	fingerprint, _, _ := fingerprintEntry(e)
+
+	h.mu.Lock()
+	cd, ok := h.cooldowns[fingerprint]
+	if ok && h.opt.cooldown > 0 && e.Time.Sub(cd.last) < h.opt.cooldown {
+		cd.suppressed++
+		h.mu.Unlock()
+		return nil
+	}
+	if !ok {
+		if len(h.cooldowns) >= _defaultAlertMaxFingerprints {
+			h.pruneLocked(e.Time)
+		}
+		cd = &alertCooldown{}
+		h.cooldowns[fingerprint] = cd
+	}
+	suppressed := cd.suppressed
+	cd.last = e.Time
+	cd.suppressed = 0
+	h.mu.Unlock()
+
+	return h.batcher.add(AlertEntry{Entry: e, Fingerprint: fingerprint, Suppressed: suppressed})
+}
+
+// pruneLocked removes the fingerprints whose cooldown has expired, their
+// suppressed counts are discarded. If all of them are still cooling down, the
+// oldest one is removed, so that the map is bounded.
+func (h *AlertHook) pruneLocked(now time.Time) {
+	var (
+		oldest     string
+		oldestLast time.Time
+	)
+	for fingerprint, cd := range h.cooldowns {
+		if now.Sub(cd.last) >= h.opt.cooldown {
+			delete(h.cooldowns, fingerprint)
+			continue
+		}
+		if oldest == "" || cd.last.Before(oldestLast) {
+			oldest, oldestLast = fingerprint, cd.last
+		}
+	}
+	if len(h.cooldowns) >= _defaultAlertMaxFingerprints {
+		delete(h.cooldowns, oldest)
+	}
+}
+
+// Sync posts the pending entries immediately.
+func (h *AlertHook) Sync() error {
+	h.batcher.sync()
+	return nil
+}
+
+// Close stops accepting entries and posts the pending entries.
+func (h *AlertHook) Close() error {
+	h.batcher.close()
+	return nil
+}
+
+// render renders entries into the request body.
+func (h *AlertHook) render(entries []AlertEntry) ([]byte, error) {
+	lines := make([]string, len(entries))
+	for i, ae := range entries {
+		lines[i] = ae.line()
+	}
+
+	buf := &bytes.Buffer{}
+	err := h.opt.tmpl.Execute(buf, Alert{Entries: entries, Text: strings.Join(lines, "\n")})
+	if err != nil {
+		return nil, errors.Wrap(err, "execute template")
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, errors.Errorf("template rendered invalid JSON: %s", buf.String())
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (h *AlertHook) post(items []interface{}) {
+	entries := make([]AlertEntry, len(items))
+	for i, item := range items {
+		entries[i] = item.(AlertEntry)
+	}
+
+	body, err := h.render(entries)
+	if err == nil {
+		err = h.opt.retry.do(func() error {
+			_, err := postHTTP(h.opt.client, h.url, h.header, body)
+			return err
+		})
+	}
+	if err == nil {
+		return
+	}
+
+	err = errors.Wrapf(err, "alert %d entries", len(entries))
+	h.mu.Lock()
+	logger := h.logger
+	h.mu.Unlock()
+	if logger == nil {
+		log.Printf("WARN: could not alert, err=%v", err)
+		return
+	}
+	logger.handleError(ErrorKindHook, err)
+}
@@ -0,0 +1,245 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWebhook records the bodies of webhook requests.
+type fakeWebhook struct {
+	mu       sync.Mutex
+	bodies   [][]byte
+	delay    time.Duration
+	failures int // responds the first failures requests with status
+	status   int
+}
+
+func (f *fakeWebhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	time.Sleep(f.delay)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failures > 0 {
+		f.failures--
+		w.WriteHeader(f.status)
+		return
+	}
+	f.bodies = append(f.bodies, body)
+	w.WriteHeader(http.StatusOK)
+}
+
+// texts returns the `text` of the default template of every request.
+func (f *fakeWebhook) texts(t *testing.T) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	texts := make([]string, 0, len(f.bodies))
+	for _, body := range f.bodies {
+		var msg struct {
+			Text string `json:"text"`
+		}
+		require.NoError(t, json.Unmarshal(body, &msg))
+		texts = append(texts, msg.Text)
+	}
+
+	return texts
+}
+
+func newAlertLogger(t *testing.T, hook *AlertHook) *Logger {
+	l, err := NewLogger(WithCustomWriter(&bytes.Buffer{}), WithHooks(hook))
+	require.NoError(t, err)
+	return l
+}
+
+func Test_AlertHook_batch(t *testing.T) {
+	fake := &fakeWebhook{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	hook, err := NewAlertHook(srv.URL, WithAlertBatch(10, time.Hour), WithAlertCooldown(0))
+	require.NoError(t, err)
+	l := newAlertLogger(t, hook)
+
+	l.Info("not alerted")
+	l.Warn("not alerted")
+	l.Error("disk is full")
+	l.Errorf("connection %d refused", 1)
+	require.NoError(t, l.Sync())
+
+	assert.Equal(t, []string{"[ERR] disk is full\n[ERR] connection 1 refused"}, fake.texts(t))
+	assert.Equal(t, []Level{LevelFatal, LevelError}, hook.Levels())
+}
+
+func Test_AlertHook_cooldown(t *testing.T) {
+	fake := &fakeWebhook{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	hook, err := NewAlertHook(srv.URL, WithAlertBatch(10, time.Hour), WithAlertCooldown(time.Minute))
+	require.NoError(t, err)
+
+	now := time.Now()
+	fire := func(at time.Time, msg string) {
+		require.NoError(t, hook.Fire(&Entry{Level: LevelError, Time: at, Message: msg}))
+	}
+	fire(now, "timeout after 3s")
+	fire(now.Add(time.Second), "timeout after 5s")
+	fire(now.Add(2*time.Second), "timeout after 7s")
+	fire(now.Add(2*time.Second), "disk is full")
+	require.NoError(t, hook.Sync())
+
+	fire(now.Add(2*time.Minute), "timeout after 9s")
+	require.NoError(t, hook.Sync())
+
+	assert.Equal(t, []string{
+		"[ERR] timeout after 3s\n[ERR] disk is full",
+		"[ERR] timeout after 9s +2 suppressed",
+	}, fake.texts(t))
+}
+
+func Test_AlertHook_template(t *testing.T) {
+	fake := &fakeWebhook{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	hook, err := NewAlertHook(srv.URL,
+		WithAlertBatch(10, time.Hour),
+		WithAlertTemplate(`{"alerts": [{{ range $i, $e := .Entries }}{{ if $i }},{{ end }}`+
+			`{"level": {{ json $e.Level.String }}, "msg": {{ json $e.Message }}, "fields": {{ json $e.Fields }}}`+
+			`{{ end }}]}`),
+	)
+	require.NoError(t, err)
+	l := newAlertLogger(t, hook)
+
+	l.WithField("db", "orders").Error(`query "x" failed`)
+	require.NoError(t, l.Close())
+
+	require.Len(t, fake.bodies, 1)
+	assert.JSONEq(t,
+		`{"alerts": [{"level": "ERR", "msg": "query \"x\" failed", "fields": {"db": "orders"}}]}`,
+		string(fake.bodies[0]))
+}
+
+func Test_AlertHook_invalid(t *testing.T) {
+	_, err := NewAlertHook("")
+	assert.Error(t, err)
+	_, err = NewAlertHook("http://localhost", WithAlertTemplate(`{"text": {{ .Text }`))
+	assert.Error(t, err)
+	_, err = NewAlertHook("http://localhost", WithAlertTimeout(0))
+	assert.Error(t, err)
+	_, err = NewAlertHook("http://localhost", WithAlertCooldown(-time.Second))
+	assert.Error(t, err)
+
+	hook, err := NewAlertHook("http://localhost", WithAlertTemplate(`{"text": {{ .Text }}}`))
+	require.NoError(t, err)
+	_, err = hook.render([]AlertEntry{{Entry: &Entry{Level: LevelError, Message: "not quoted"}}})
+	assert.Error(t, err)
+	require.NoError(t, hook.Close())
+}
+
+func Test_AlertHook_nonBlocking(t *testing.T) {
+	fake := &fakeWebhook{delay: time.Second}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	hook, err := NewAlertHook(srv.URL,
+		WithAlertBatch(1, time.Hour),
+		WithAlertCooldown(0),
+		WithAlertTimeout(100*time.Millisecond),
+		WithAlertRetry(0, 0),
+	)
+	require.NoError(t, err)
+	l := newAlertLogger(t, hook)
+
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		l.Errorf("error %d", i)
+	}
+	assert.Less(t, int64(time.Since(start)), int64(500*time.Millisecond))
+	assert.NotZero(t, l.ErrorStats().HookErrors, "entries dropped when the queue is full")
+	require.NoError(t, hook.Close())
+}
+
+func Test_AlertHook_retry(t *testing.T) {
+	fake := &fakeWebhook{failures: 2, status: http.StatusServiceUnavailable}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	hook, err := NewAlertHook(srv.URL,
+		WithAlertBatch(10, time.Hour),
+		WithAlertRetry(3, time.Millisecond),
+	)
+	require.NoError(t, err)
+	l := newAlertLogger(t, hook)
+
+	l.Error("retried")
+	require.NoError(t, hook.Sync())
+
+	assert.Equal(t, []string{"[ERR] retried"}, fake.texts(t))
+	assert.Zero(t, l.ErrorStats().HookErrors)
+	require.NoError(t, hook.Close())
+}
+
+func Test_AlertHook_errors(t *testing.T) {
+	fake := &fakeWebhook{failures: 10, status: http.StatusBadRequest}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	hook, err := NewAlertHook(srv.URL,
+		WithAlertBatch(10, time.Hour),
+		WithAlertRetry(3, time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	var (
+		mu    sync.Mutex
+		kinds []ErrorKind
+	)
+	l, err := NewLogger(WithCustomWriter(&bytes.Buffer{}), WithErrorHandler(func(kind ErrorKind, err error) {
+		mu.Lock()
+		kinds = append(kinds, kind)
+		mu.Unlock()
+	}))
+	require.NoError(t, err)
+	l.AddHook(hook)
+
+	l.Error("rejected")
+	require.NoError(t, hook.Sync())
+
+	mu.Lock()
+	assert.Equal(t, []ErrorKind{ErrorKindHook}, kinds)
+	mu.Unlock()
+	assert.Equal(t, uint64(1), l.ErrorStats().HookErrors)
+	fake.mu.Lock()
+	assert.Equal(t, 9, fake.failures, "4xx is not retried")
+	fake.mu.Unlock()
+	require.NoError(t, hook.Close())
+}
+
+func Test_AlertHook_pruneBounded(t *testing.T) {
+	hook, err := NewAlertHook("http://127.0.0.1:0", WithAlertCooldown(time.Hour))
+	require.NoError(t, err)
+	defer hook.Close()
+
+	now := time.Now()
+	hook.mu.Lock()
+	for i := 0; i < _defaultAlertMaxFingerprints; i++ {
+		hook.cooldowns[strconv.Itoa(i)] = &alertCooldown{last: now.Add(time.Duration(i) * time.Millisecond)}
+	}
+	hook.pruneLocked(now)
+	_, ok := hook.cooldowns["0"]
+	assert.False(t, ok, "the oldest is evicted though it's cooling down")
+	assert.Len(t, hook.cooldowns, _defaultAlertMaxFingerprints-1)
+	hook.mu.Unlock()
+}
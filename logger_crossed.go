@@ -0,0 +1,127 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// heldEntry is a formatted entry held in holdBuffer.
+type heldEntry struct {
+	lv     Level
+	data   []byte
+	fields Fields // only kept for fieldsWriter
+}
+
+// holdBuffer is a ring buffer of formatted entries those would be written
+// later, the oldest one would be discarded if it's full.
+type holdBuffer struct {
+	mu        sync.Mutex
+	entries   []heldEntry // data and fields are reused
+	next      int         // next position to hold
+	n         int         // count of held entries
+	discarded uint64
+}
+
+func newHoldBuffer(size int) holdBuffer {
+	return holdBuffer{entries: make([]heldEntry, size)}
+}
+
+// hold copies data and fields into the buffer, it returns true if the
+// oldest held entry has been discarded.
+func (b *holdBuffer) hold(lv Level, fields Fields, data []byte) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.holdLocked(lv, fields, data)
+}
+
+func (b *holdBuffer) holdLocked(lv Level, fields Fields, data []byte) bool {
+	held := &b.entries[b.next]
+	held.lv = lv
+	held.data = append(held.data[:0], data...)
+	held.fields = nil
+	if fields != nil {
+		held.fields = make(Fields, len(fields))
+		copyFields(held.fields, fields)
+	}
+
+	if b.next++; b.next == len(b.entries) {
+		b.next = 0
+	}
+	if b.n < len(b.entries) {
+		b.n++
+		return false
+	}
+	atomic.AddUint64(&b.discarded, 1)
+	return true
+}
+
+// release calls fn with the held entries from the oldest to the newest,
+// and empties the buffer.
+func (b *holdBuffer) release(fn func(lv Level, fields Fields, data []byte)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.releaseLocked(fn)
+}
+
+func (b *holdBuffer) releaseLocked(fn func(lv Level, fields Fields, data []byte)) {
+	start := b.next - b.n
+	if start < 0 {
+		start += len(b.entries)
+	}
+	for i := 0; i < b.n; i++ {
+		held := &b.entries[(start+i)%len(b.entries)]
+		fn(held.lv, held.fields, held.data)
+		held.fields = nil
+	}
+	b.n = 0
+}
+
+// fingersCrossed holds the formatted entries less severe than trigger, they
+// are written before the next entry of trigger level or more severe.
+type fingersCrossed struct {
+	trigger Level
+	holdBuffer
+}
+
+func newFingersCrossed(trigger Level, size int) *fingersCrossed {
+	return &fingersCrossed{
+		trigger:    trigger,
+		holdBuffer: newHoldBuffer(size),
+	}
+}
+
+// WithFingersCrossed holds entries less severe than trigger in a buffer of
+// size instead of writing them. When an entry of trigger level or more severe
+// arrives, the held entries are written before it, so that failures come with
+// full context while logs keep quiet otherwise. The oldest held entry would be
+// discarded if the buffer is full. Sinks, hooks and FlightRecorder are not
+// affected, and held entries are discarded after the Logger has been closed.
+//
+// Set the level by WithLevel to decide which entries could be held, such as
+// LevelDebug to hold debug entries.
+func WithFingersCrossed(trigger Level, size int) LoggerOption {
+	return func(lo *options) error {
+		if trigger > LevelDebug {
+			return errors.Errorf("WithFingersCrossed: unknown level %d", trigger)
+		}
+		if size <= 0 {
+			return errors.Errorf("WithFingersCrossed: size must be positive, got %d", size)
+		}
+		lo.crossed = newFingersCrossed(trigger, size)
+		return nil
+	}
+}
+
+// HeldDiscarded returns the count of held entries those have been discarded
+// since the buffer of WithFingersCrossed is full.
+func (l *Logger) HeldDiscarded() uint64 {
+	if l.opt.crossed == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&l.opt.crossed.discarded)
+}
@@ -0,0 +1,89 @@
+package log
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// messages returns the last word of every line.
+func messages(s string) []string {
+	var msgs []string
+	for _, line := range strings.Split(strings.TrimSpace(s), "\n") {
+		if line == "" {
+			continue
+		}
+		words := strings.Fields(line)
+		msgs = append(msgs, words[len(words)-1])
+	}
+
+	return msgs
+}
+
+func Test_Logger_FingersCrossed(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l, err := NewLogger(WithCustomWriter(buf), WithFingersCrossed(LevelError, 3))
+	require.NoError(t, err)
+
+	l.Debug("d1")
+	l.Info("i1")
+	assert.Empty(t, buf.String(), "entries are held")
+
+	l.Error("e1")
+	assert.Equal(t, []string{"d1", "i1", "e1"}, messages(buf.String()))
+
+	buf.Reset()
+	l.Warn("w1")
+	l.Error("e2")
+	assert.Equal(t, []string{"w1", "e2"}, messages(buf.String()), "held entries are written once")
+
+	buf.Reset()
+	for _, msg := range []string{"d2", "d3", "d4", "d5", "d6"} {
+		l.Debug(msg)
+	}
+	l.Error("e3")
+	assert.Equal(t, []string{"d4", "d5", "d6", "e3"}, messages(buf.String()))
+	assert.Equal(t, uint64(2), l.HeldDiscarded())
+}
+
+func Test_Logger_FingersCrossed_level(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l, err := NewLogger(
+		WithCustomWriter(buf),
+		WithLevel(LevelInfo),
+		WithFingersCrossed(LevelWarning, 10),
+	)
+	require.NoError(t, err)
+
+	l.Debug("filtered")
+	l.Info("held")
+	l.Warn("trigger")
+	assert.Equal(t, []string{"held", "trigger"}, messages(buf.String()))
+}
+
+func Test_Logger_FingersCrossed_router(t *testing.T) {
+	dir := newTestRouterDir(t)
+	router, err := NewFileRouter(filepath.Join(dir, "{tenant}.log"), WithRouterRotate(false))
+	require.NoError(t, err)
+	l, err := NewLogger(WithCustomWriter(router), WithFingersCrossed(LevelError, 10))
+	require.NoError(t, err)
+
+	l.WithField("tenant", "a").Debug("a1")
+	l.WithField("tenant", "b").Debug("b1")
+	l.WithField("tenant", "b").Error("b2")
+	require.NoError(t, l.Close())
+
+	assert.Equal(t, []string{"a1"}, messages(readFile(t, filepath.Join(dir, "a.log"))))
+	assert.Equal(t, []string{"b1", "b2"}, messages(readFile(t, filepath.Join(dir, "b.log"))))
+}
+
+func Test_WithFingersCrossed_invalid(t *testing.T) {
+	_, err := NewLogger(WithFingersCrossed(LevelError, 0))
+	assert.Error(t, err)
+	_, err = NewLogger(WithFingersCrossed(Level(10), 1))
+	assert.Error(t, err)
+}
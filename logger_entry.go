@@ -4,12 +4,16 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"log"
 	"os"
+	"runtime"
 	"strconv"
+	"sync/atomic"
 	"time"
 )
 
+// exit terminates the process after Fatal, it's replaced in tests.
+var exit = os.Exit
+
 type entry struct {
 	logger     *Logger   // logger pointer
 	out        io.Writer // write to record
@@ -24,6 +28,12 @@ type entry struct {
 
 	ctx       context.Context
 	ctxParser ContextParser
+
+	sinks    []Sink          // sinks to emit structured entry
+	recorder *FlightRecorder // recorder keeps entries of every level
+
+	// direct entries bypass the level check and processors, see logDirect.
+	direct bool
 }
 
 func newEntry(l *Logger) *entry {
@@ -44,6 +54,8 @@ func newEntry(l *Logger) *entry {
 		fields:     make(Fields, 4),
 		ctx:        nil,
 		ctxParser:  l.opt.ctxParser,
+		sinks:      l.opt.sinks,
+		recorder:   l.opt.recorder,
 	}
 
 	if l.opt.globalFields != nil && len(l.opt.globalFields) != 0 {
@@ -68,6 +80,9 @@ func (e *entry) copy() *entry {
 		fields:     dst,
 		ctx:        e.ctx,
 		ctxParser:  e.ctxParser,
+		sinks:      e.sinks,
+		recorder:   e.recorder,
+		direct:     e.direct,
 	}
 
 	return newer
@@ -98,16 +113,29 @@ func (e *entry) reset() {
 	e.ctx = nil
 	e.ctxParser = nil
 	e.withCaller = false
+	e.sinks = nil
+	e.recorder = nil
+	e.direct = false
 }
 
 func (e *entry) Fatal(args ...interface{}) {
 	e.output(LevelFatal, fmt.Sprint(args...))
-	os.Exit(1)
+	e.beforeExit()
+	exit(1)
 }
 
 func (e *entry) Fatalf(format string, v ...interface{}) {
 	e.output(LevelFatal, fmt.Sprintf(format, v...))
-	os.Exit(1)
+	e.beforeExit()
+	exit(1)
+}
+
+// beforeExit dumps the flight recorder and syncs the logger before exiting.
+func (e *entry) beforeExit() {
+	if e.recorder != nil {
+		e.recorder.dump()
+	}
+	_ = e.logger.Sync()
 }
 
 func (e *entry) Error(args ...interface{}) {
@@ -142,8 +170,64 @@ func (e *entry) Debugf(format string, v ...interface{}) {
 	e.output(LevelDebug, fmt.Sprintf(format, v...))
 }
 
+// write writes data into out, writers those route by fields get fields too.
+func (e *entry) write(fields Fields, data []byte) (int, error) {
+	if fw, ok := e.out.(fieldsWriter); ok {
+		return fw.writeFields(fields, data)
+	}
+
+	return e.out.Write(data)
+}
+
+// writeOut writes data of lv into the writer, the fallback writer would be
+// used if the writer failed.
+func (e *entry) writeOut(lv Level, fields Fields, data []byte) {
+	n, err := e.write(fields, data)
+	if err != nil {
+		e.logger.handleError(ErrorKindWrite, err)
+		e.logger.writeFallback(data)
+		return
+	}
+
+	atomic.AddUint64(&e.logger.metrics.bytes, uint64(n))
+	e.logger.metrics.incrEntry(lv)
+	e.logger.commit(lv)
+}
+
+// heldFields returns the fields those should be held with data, they are
+// only needed by fieldsWriter.
+func (e *entry) heldFields() Fields {
+	if _, ok := e.out.(fieldsWriter); ok {
+		return e.fields
+	}
+
+	return nil
+}
+
+// writeCrossed holds data if it's less severe than the trigger of
+// fingersCrossed, or writes the held entries and data.
+func (e *entry) writeCrossed(fc *fingersCrossed, lv Level, data []byte) {
+	if lv > fc.trigger {
+		if fc.hold(lv, e.heldFields(), data) {
+			e.logger.metrics.incrDropped()
+		}
+		return
+	}
+
+	fc.release(e.writeOut)
+	e.writeOut(lv, e.fields, data)
+}
+
 func (e *entry) output(lv Level, msg string) {
-	if e.lv < lv {
+	threshold := e.lv
+	if e.lv < lv && !e.direct {
+		// the flight recorder keeps entries those are filtered too,
+		// caller and context are skipped to keep it cheap.
+		if e.recorder != nil {
+			fields := make(Fields, len(e.fields))
+			copyFields(fields, e.fields)
+			e.recorder.record(&Entry{Level: lv, Message: msg, Time: time.Now(), Fields: fields})
+		}
 		return
 	}
 
@@ -155,12 +239,13 @@ func (e *entry) output(lv Level, msg string) {
 		//Fn:            fn,
 	}
 
+	var frm *runtime.Frame
 	if e.withCaller {
 		file := "failed"
 		fn := "failed"
 		line := 0
 
-		frm := getCaller()
+		frm = getCaller()
 		if frm != nil {
 			file = frm.File
 			fn = frm.Function
@@ -180,17 +265,66 @@ func (e *entry) output(lv Level, msg string) {
 		e.fields[e.ctxParser.FieldName()] = _ctxValue
 	}
 
-	// format message
+	// fields and context have been resolved, apply processors on the
+	// snapshot, and output the processed one. The fields of entry would be
+	// restored since the entry may be used again.
+	var snapshot *Entry
+	if processors := e.logger.opt.processors; len(processors) != 0 && !e.direct {
+		snapshot = newEntrySnapshot(e, msg, now, frm)
+		if !process(processors, snapshot, threshold) {
+			e.logger.metrics.incrDropped()
+			return
+		}
+
+		fields := e.fields
+		defer func() { e.fields = fields }()
+		e.fields, e.lv, msg = snapshot.Fields, snapshot.Level, snapshot.Message
+		lv = snapshot.Level
+	}
+
+	// build the snapshot for hooks, sinks and recorder, and fire hooks.
+	hooks := e.logger.hooks.get(lv)
+	if snapshot == nil && (len(hooks) != 0 || len(e.sinks) != 0 || e.recorder != nil) {
+		snapshot = newEntrySnapshot(e, msg, now, frm)
+	}
+	for _, hook := range hooks {
+		if err := hook.Fire(snapshot); err != nil {
+			e.logger.handleError(ErrorKindHook, err)
+		}
+	}
+
+	// format message and write into writer, the fallback writer would be
+	// used if the writer failed or the logger has been closed. An entry is
+	// always written by one Write call, so lines would never interleave.
+	closed := e.logger.isClosed()
 	data, err := e.formatter.Format(e, msg)
 	if err != nil {
 		// FIXED: throw error in a way not panic
 		// panic(err)
-		log.Printf("WARN: could not format message, err=%v", err)
+		e.logger.handleError(ErrorKindFormat, err)
+	} else if closed {
+		e.logger.writeClosed(data)
+	} else if sc := e.scope(); sc != nil && sc.hold(e, lv, data) {
+		// held by the scope until it ends.
+	} else if fc := e.logger.opt.crossed; fc != nil {
+		e.writeCrossed(fc, lv, data)
+	} else {
+		e.writeOut(lv, e.fields, data)
 	}
 
-	// write into writer
-	if _, err = e.out.Write(data); err != nil {
-		// panic(err)
-		log.Printf("WARN: could not write log data, err=%v", err)
+	// emit into sinks and recorder
+	if snapshot == nil {
+		return
+	}
+	if e.recorder != nil {
+		e.recorder.record(snapshot)
+	}
+	if closed {
+		return
+	}
+	for _, sink := range e.sinks {
+		if err = sink.Emit(snapshot); err != nil {
+			e.logger.handleError(ErrorKindSink, err)
+		}
 	}
 }
@@ -0,0 +1,153 @@
+package log
+
+import (
+	"log"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// ErrorKind indicates in which stage an error occurs while outputting.
+type ErrorKind uint8
+
+const (
+	// ErrorKindFormat means Formatter failed to format entry.
+	ErrorKindFormat ErrorKind = iota
+	// ErrorKindWrite means the writer failed to write.
+	ErrorKindWrite
+	// ErrorKindFallback means the fallback writer failed to write too.
+	ErrorKindFallback
+	// ErrorKindSink means a Sink failed to emit.
+	ErrorKindSink
+	// ErrorKindSync means the file failed to fsync by FsyncPolicy.
+	ErrorKindSync
+	// ErrorKindHook means a Hook failed to fire.
+	ErrorKindHook
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorKindFormat:
+		return "format"
+	case ErrorKindWrite:
+		return "write"
+	case ErrorKindFallback:
+		return "fallback"
+	case ErrorKindSink:
+		return "sink"
+	case ErrorKindSync:
+		return "sync"
+	case ErrorKindHook:
+		return "hook"
+	}
+
+	return "unknown"
+}
+
+// ErrorHandler would be called with every error occurs while outputting,
+// it's called synchronously so it should be fast, and it must not log by
+// the same Logger, otherwise it may recurse infinitely.
+type ErrorHandler func(kind ErrorKind, err error)
+
+// defaultErrorHandler prints the error by standard library log.
+func defaultErrorHandler(kind ErrorKind, err error) {
+	switch kind {
+	case ErrorKindFormat:
+		log.Printf("WARN: could not format message, err=%v", err)
+	case ErrorKindWrite:
+		log.Printf("WARN: could not write log data, err=%v", err)
+	case ErrorKindFallback:
+		log.Printf("WARN: could not write log data into fallback, err=%v", err)
+	case ErrorKindSink:
+		log.Printf("WARN: could not emit log entry, err=%v", err)
+	case ErrorKindSync:
+		log.Printf("WARN: could not sync log file, err=%v", err)
+	case ErrorKindHook:
+		log.Printf("WARN: could not fire hook, err=%v", err)
+	default:
+		log.Printf("WARN: %s error, err=%v", kind, err)
+	}
+}
+
+// ErrorStats is the counters of errors occurred while outputting,
+// they could be exported as metrics by the application.
+type ErrorStats struct {
+	FormatErrors   uint64 // count of Formatter errors.
+	WriteErrors    uint64 // count of writer errors.
+	FallbackWrites uint64 // count of entries written into fallback writer successfully.
+	FallbackErrors uint64 // count of fallback writer errors.
+	SinkErrors     uint64 // count of Sink errors.
+	SyncErrors     uint64 // count of fsync errors.
+	HookErrors     uint64 // count of Hook errors.
+}
+
+// errorCounters are atomic counters of ErrorStats.
+type errorCounters struct {
+	format         uint64
+	write          uint64
+	fallbackWrites uint64
+	fallback       uint64
+	sink           uint64
+	sync           uint64
+	hook           uint64
+}
+
+func (c *errorCounters) incr(kind ErrorKind) {
+	switch kind {
+	case ErrorKindFormat:
+		atomic.AddUint64(&c.format, 1)
+	case ErrorKindWrite:
+		atomic.AddUint64(&c.write, 1)
+	case ErrorKindFallback:
+		atomic.AddUint64(&c.fallback, 1)
+	case ErrorKindSink:
+		atomic.AddUint64(&c.sink, 1)
+	case ErrorKindSync:
+		atomic.AddUint64(&c.sync, 1)
+	case ErrorKindHook:
+		atomic.AddUint64(&c.hook, 1)
+	}
+}
+
+func (c *errorCounters) stats() ErrorStats {
+	return ErrorStats{
+		FormatErrors:   atomic.LoadUint64(&c.format),
+		WriteErrors:    atomic.LoadUint64(&c.write),
+		FallbackWrites: atomic.LoadUint64(&c.fallbackWrites),
+		FallbackErrors: atomic.LoadUint64(&c.fallback),
+		SinkErrors:     atomic.LoadUint64(&c.sink),
+		SyncErrors:     atomic.LoadUint64(&c.sync),
+		HookErrors:     atomic.LoadUint64(&c.hook),
+	}
+}
+
+// handleError counts err and hands it to the error handler.
+func (l *Logger) handleError(kind ErrorKind, err error) {
+	l.errCounters.incr(kind)
+	if errors.Is(err, errSinkBufferFull) {
+		l.metrics.incrDropped()
+	}
+	if h := l.opt.errorHandler; h != nil {
+		h(kind, err)
+	}
+}
+
+// writeFallback writes data into the fallback writer if it's set,
+// it's called after the writer failed.
+func (l *Logger) writeFallback(data []byte) {
+	fallback := l.opt.fallback
+	if fallback == nil {
+		return
+	}
+
+	if _, err := fallback.Write(data); err != nil {
+		l.handleError(ErrorKindFallback, err)
+		return
+	}
+	atomic.AddUint64(&l.errCounters.fallbackWrites, 1)
+}
+
+// ErrorStats returns the counters of errors occurred while outputting.
+func (l *Logger) ErrorStats() ErrorStats {
+	return l.errCounters.stats()
+}
@@ -0,0 +1,72 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errBrokenWriter = errors.New("broken writer")
+
+// brokenWriter always fails to write.
+type brokenWriter struct{}
+
+func (brokenWriter) Write(p []byte) (int, error) {
+	return 0, errBrokenWriter
+}
+
+func Test_Logger_ErrorHandler(t *testing.T) {
+	type handled struct {
+		kind ErrorKind
+		err  error
+	}
+	var errs []handled
+
+	fallback := &bytes.Buffer{}
+	l, err := NewLogger(
+		WithCustomWriter(brokenWriter{}),
+		WithFallbackWriter(fallback),
+		WithErrorHandler(func(kind ErrorKind, err error) {
+			errs = append(errs, handled{kind: kind, err: err})
+		}),
+		WithSinks(SinkFunc(func(e *Entry) error {
+			return errors.New("sink failed")
+		})),
+	)
+	require.NoError(t, err)
+
+	l.Info("to fallback")
+	assert.Contains(t, fallback.String(), "to fallback")
+	require.Len(t, errs, 2)
+	assert.Equal(t, ErrorKindWrite, errs[0].kind)
+	assert.Equal(t, errBrokenWriter, errs[0].err)
+	assert.Equal(t, ErrorKindSink, errs[1].kind)
+
+	assert.Equal(t, ErrorStats{WriteErrors: 1, FallbackWrites: 1, SinkErrors: 1}, l.ErrorStats())
+}
+
+func Test_Logger_FallbackFailed(t *testing.T) {
+	l, err := NewLogger(
+		WithCustomWriter(brokenWriter{}),
+		WithFallbackWriter(brokenWriter{}),
+		WithErrorHandler(nil),
+	)
+	require.NoError(t, err)
+
+	l.Info("lost")
+	l.Info("lost again")
+	assert.Equal(t, ErrorStats{WriteErrors: 2, FallbackErrors: 2}, l.ErrorStats())
+}
+
+func Test_ErrorKind_String(t *testing.T) {
+	assert.Equal(t, "format", ErrorKindFormat.String())
+	assert.Equal(t, "write", ErrorKindWrite.String())
+	assert.Equal(t, "fallback", ErrorKindFallback.String())
+	assert.Equal(t, "sink", ErrorKindSink.String())
+	assert.Equal(t, "sync", ErrorKindSync.String())
+	assert.Equal(t, "hook", ErrorKindHook.String())
+	assert.Equal(t, "unknown", ErrorKind(100).String())
+}
@@ -0,0 +1,472 @@
+package log
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// errWriterClosed would be returned if the writer has been closed.
+var errWriterClosed = errors.New("writer has been closed")
+
+const (
+	// _lockFileSuffix is the suffix of lock file for shared rotation.
+	_lockFileSuffix = ".lock"
+	// _sharedCheckInterval is the interval to check whether the shared
+	// file has been rotated by other processes while writing.
+	_sharedCheckInterval = time.Second
+)
+
+type fsyncKind uint8
+
+const (
+	fsyncNever fsyncKind = iota
+	fsyncEveryN
+	fsyncInterval
+	fsyncLevel
+)
+
+// FsyncPolicy decides when the log file would be committed to stable storage,
+// it's a trade-off between durability and throughput.
+type FsyncPolicy struct {
+	kind     fsyncKind
+	n        int
+	interval time.Duration
+	lv       Level
+}
+
+// FsyncNever never calls fsync explicitly, the OS flushes its page cache at
+// its own pace. It's the default policy.
+func FsyncNever() FsyncPolicy {
+	return FsyncPolicy{kind: fsyncNever}
+}
+
+// FsyncEveryN calls fsync after every n entries have been written.
+func FsyncEveryN(n int) FsyncPolicy {
+	return FsyncPolicy{kind: fsyncEveryN, n: n}
+}
+
+// FsyncInterval calls fsync every interval if anything has been written since
+// the last fsync.
+func FsyncInterval(interval time.Duration) FsyncPolicy {
+	return FsyncPolicy{kind: fsyncInterval, interval: interval}
+}
+
+// FsyncOnLevel calls fsync after every entry whose level is lv or more severe,
+// such as FsyncOnLevel(LevelError) syncs Error and Fatal entries.
+func FsyncOnLevel(lv Level) FsyncPolicy {
+	return FsyncPolicy{kind: fsyncLevel, lv: lv}
+}
+
+func (p FsyncPolicy) validate() error {
+	switch p.kind {
+	case fsyncNever, fsyncLevel:
+		return nil
+	case fsyncEveryN:
+		if p.n <= 0 {
+			return errors.Errorf("FsyncEveryN: n must be positive, got %d", p.n)
+		}
+		return nil
+	case fsyncInterval:
+		if p.interval <= 0 {
+			return errors.Errorf("FsyncInterval: interval must be positive, got %s", p.interval)
+		}
+		return nil
+	}
+
+	return errors.Errorf("unknown fsync policy %d", p.kind)
+}
+
+// perEntry reports whether the policy should be applied after every entry.
+func (p FsyncPolicy) perEntry() bool {
+	return p.kind == fsyncEveryN || p.kind == fsyncLevel
+}
+
+// FileOption to apply single function into `fo`.
+type FileOption func(fo *fileOptions) error
+
+type fileOptions struct {
+	fsync  FsyncPolicy
+	shared bool
+}
+
+func defaultFileOptions() *fileOptions {
+	return &fileOptions{
+		fsync: FsyncNever(),
+	}
+}
+
+// WithFsync sets the fsync policy of log file, errors of fsync would be
+// handed to the ErrorHandler with ErrorKindSync.
+func WithFsync(policy FsyncPolicy) FileOption {
+	return func(fo *fileOptions) error {
+		if err := policy.validate(); err != nil {
+			return errors.Wrap(err, "WithFsync")
+		}
+		fo.fsync = policy
+		return nil
+	}
+}
+
+// WithSharedRotation makes the file safe to be appended and rotated by several
+// processes on the same host. Rotation is coordinated by flock on the lock
+// file `{file}.lock`: exactly one process renames the file, and the others
+// detect that the file has been replaced and reopen it. It's only supported
+// on unix-like systems.
+func WithSharedRotation() FileOption {
+	return func(fo *fileOptions) error {
+		if !_flockSupported {
+			return errors.New("WithSharedRotation: flock is not supported on this platform")
+		}
+		fo.shared = true
+		return nil
+	}
+}
+
+// committer is implemented by writers which need to know that an entry has
+// been written completely, such as the fileWriter with FsyncPolicy.
+type committer interface {
+	commit(lv Level) error
+}
+
+// fileWriter writes into a log file, if autoRotate is set, it starts a
+// goroutine to split the file by day. The fd is swapped under lock when
+// rotating, so the writer could be shared by MultiWriter safely.
+type fileWriter struct {
+	mu      sync.Mutex
+	fd      *os.File
+	closed  bool
+	dirty   bool // written since last fsync
+	pending int  // entries written since last fsync, used by FsyncEveryN
+
+	path      string // the path which is opened, used to stat, reopen and rename
+	dir       string
+	filename  string // pure filename without dir
+	fsync     FsyncPolicy
+	lastSplit time.Time // last time when split the file
+
+	shared    bool      // several processes share the file, see WithSharedRotation
+	lockFd    *os.File  // lock file to coordinate rotation between processes
+	lastCheck time.Time // last time when checked whether the file was replaced
+
+	stop chan struct{}  // stop the background goroutines
+	wg   sync.WaitGroup // waits for the background goroutines
+
+	// onErrors report the errors of background fsync to the Loggers, keyed
+	// by the handles bound to them, guarded by mu.
+	onErrors map[*fileHandle]func(err error)
+}
+
+// newFileWriter opens abs and starts rotating goroutine if autoRotate is set.
+func newFileWriter(abs string, autoRotate bool, fo *fileOptions) (*fileWriter, error) {
+	fd, err := open(abs)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, filename := filepath.Split(abs)
+	w := &fileWriter{
+		fd:        fd,
+		path:      abs,
+		dir:       dir,
+		filename:  filename,
+		fsync:     fo.fsync,
+		lastSplit: time.Now(),
+		shared:    fo.shared,
+		lastCheck: time.Now(),
+		stop:      make(chan struct{}),
+	}
+	if w.shared {
+		if w.lockFd, err = open(abs + _lockFileSuffix); err != nil {
+			_ = fd.Close()
+			return nil, errors.Wrap(err, "open lock file")
+		}
+	}
+
+	// judge whether auto rotate enabled or not, if not enabled, return here.
+	if autoRotate {
+		w.wg.Add(1)
+		go w.rotateLoop()
+	}
+	if w.fsync.kind == fsyncInterval {
+		w.wg.Add(1)
+		go w.syncLoop()
+	}
+
+	return w, nil
+}
+
+func (w *fileWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, errWriterClosed
+	}
+
+	// the shared file may have been rotated by another process.
+	if w.shared {
+		if now := time.Now(); now.Sub(w.lastCheck) >= _sharedCheckInterval {
+			w.lastCheck = now
+			if moved, err := w.movedLocked(); err == nil && moved {
+				_ = w.reopenLocked()
+			}
+		}
+	}
+
+	w.dirty = true
+	return w.fd.Write(p)
+}
+
+// commit applies the per entry fsync policy after an entry of lv was written.
+func (w *fileWriter) commit(lv Level) error {
+	switch w.fsync.kind {
+	case fsyncEveryN:
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if w.pending++; w.pending < w.fsync.n {
+			return nil
+		}
+		return w.syncLocked()
+	case fsyncLevel:
+		if lv > w.fsync.lv {
+			return nil
+		}
+		return w.Sync()
+	}
+
+	return nil
+}
+
+// Sync commits the content of file to stable storage.
+func (w *fileWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.syncLocked()
+}
+
+func (w *fileWriter) syncLocked() error {
+	if w.closed {
+		return nil
+	}
+
+	w.dirty = false
+	w.pending = 0
+	return w.fd.Sync()
+}
+
+// Close stops background goroutines, syncs and closes the file, it's safe to
+// be called more than once.
+func (w *fileWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+
+	close(w.stop)
+	w.wg.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.lockFd != nil {
+		_ = w.lockFd.Close()
+	}
+	_ = w.fd.Sync()
+	return w.fd.Close()
+}
+
+// syncLoop calls fsync every interval if the file is dirty.
+func (w *fileWriter) syncLoop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.fsync.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.mu.Lock()
+			var err error
+			if w.dirty {
+				err = w.syncLocked()
+			}
+			var onErrors []func(err error)
+			if err != nil {
+				for _, fn := range w.onErrors {
+					onErrors = append(onErrors, fn)
+				}
+			}
+			w.mu.Unlock()
+			if err == nil {
+				continue
+			}
+
+			err = errors.Wrapf(err, "sync log file %s", w.path)
+			if len(onErrors) == 0 {
+				log.Printf("WARN: could not sync log file, err=%v", err)
+			}
+			for _, fn := range onErrors {
+				fn(err)
+			}
+		}
+	}
+}
+
+// onError sets fn to report errors of background fsync for handle h, nil fn
+// removes it.
+func (w *fileWriter) onError(h *fileHandle, fn func(err error)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if fn == nil {
+		delete(w.onErrors, h)
+		return
+	}
+	if w.onErrors == nil {
+		w.onErrors = make(map[*fileHandle]func(err error), 1)
+	}
+	w.onErrors[h] = fn
+}
+
+// rotateLoop splits log file by day.
+// TODO(@yeqown): using time round instead of ticker
+func (w *fileWriter) rotateLoop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case tick := <-ticker.C:
+			if !w.shouldRotate(tick) {
+				continue
+			}
+			if err := w.rotate(); err != nil {
+				fmt.Printf("rotate failed dir: %s, filename: %s err: %v \n", w.dir, w.filename, err)
+				continue
+			}
+
+			// record the splitting time, every file keeps its own time,
+			// so that files those share the goroutine timing would not
+			// skip the rotation of each other.
+			w.lastSplit = time.Now()
+		}
+	}
+}
+
+// shouldRotate judges by now and lastSplit, if now is another day from
+// lastSplit, then the file should be rotated.
+func (w *fileWriter) shouldRotate(now time.Time) bool {
+	return now.Day() != w.lastSplit.Day()
+}
+
+// rotate renames the current file to old filename, and opens a new one.
+func (w *fileWriter) rotate() error {
+	if w.shared {
+		return w.rotateShared()
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+
+	// rename fp to old filename
+	if err := os.Rename(w.path, w.rotatedPath()); err != nil {
+		return errors.Wrap(err, "rename")
+	}
+
+	return w.reopenLocked()
+}
+
+// rotateShared rotates the file while holding the lock file, so that only
+// one process renames the file. The processes come later would find that
+// the file has been replaced or the renamed file exists, then they only
+// reopen the file.
+func (w *fileWriter) rotateShared() error {
+	if err := lockFile(w.lockFd); err != nil {
+		return errors.Wrap(err, "lock")
+	}
+	defer func() { _ = unlockFile(w.lockFd) }()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+
+	moved, err := w.movedLocked()
+	if err != nil {
+		return errors.Wrap(err, "stat")
+	}
+	if moved {
+		return w.reopenLocked()
+	}
+
+	// the file has been rotated before it was opened by this process.
+	rotated := w.rotatedPath()
+	if _, err = os.Stat(rotated); err == nil {
+		return nil
+	}
+
+	if err = os.Rename(w.path, rotated); err != nil {
+		return errors.Wrap(err, "rename")
+	}
+
+	return w.reopenLocked()
+}
+
+// rotatedPath returns the path which the file would be renamed to, such as
+// `app.log-20200730`.
+func (w *fileWriter) rotatedPath() string {
+	return filepath.Join(w.dir, rotateFilenameAt(w.filename, w.lastSplit))
+}
+
+// movedLocked reports whether the file has been renamed or removed.
+func (w *fileWriter) movedLocked() (bool, error) {
+	cur, err := os.Stat(w.path)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	opened, err := w.fd.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	return !os.SameFile(cur, opened), nil
+}
+
+// reopenLocked opens the file again and swaps the fd.
+func (w *fileWriter) reopenLocked() error {
+	fd, err := open(w.path)
+	if err != nil {
+		return errors.Wrap(err, "open")
+	}
+	_ = w.fd.Sync()
+	_ = w.fd.Close()
+	w.fd = fd
+
+	return nil
+}
@@ -0,0 +1,22 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package log
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+const _flockSupported = false
+
+var errFlockNotSupported = errors.New("flock is not supported on this platform")
+
+func lockFile(f *os.File) error {
+	return errFlockNotSupported
+}
+
+func unlockFile(f *os.File) error {
+	return errFlockNotSupported
+}
@@ -0,0 +1,160 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	_sharedHelperFileEnv = "LOG_SHARED_ROTATION_FILE"
+	_sharedHelperIDEnv   = "LOG_SHARED_ROTATION_ID"
+	_sharedProcesses     = 4
+	_sharedLines         = 500
+)
+
+var _sharedRotationDate = time.Date(2020, 1, 2, 0, 0, 0, 0, time.Local)
+
+// Test_sharedRotationHelper is the child process of Test_fileWriter_sharedRotation,
+// it writes lines and rotates the shared file in the middle.
+func Test_sharedRotationHelper(t *testing.T) {
+	fp := os.Getenv(_sharedHelperFileEnv)
+	if fp == "" {
+		t.Skip("only runs as child process")
+	}
+	id := os.Getenv(_sharedHelperIDEnv)
+
+	w, err := newFileWriter(fp, false, &fileOptions{fsync: FsyncNever(), shared: true})
+	require.NoError(t, err)
+	w.lastSplit = _sharedRotationDate
+
+	for n := 0; n < _sharedLines; n++ {
+		if n == _sharedLines/2 {
+			require.NoError(t, w.rotate())
+		}
+		_, err = fmt.Fprintf(w, "id=%s n=%d\n", id, n)
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+}
+
+func Test_fileWriter_sharedRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-shared")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fp := filepath.Join(dir, "app.log")
+
+	cmds := make([]*exec.Cmd, 0, _sharedProcesses)
+	outputs := make([]*bytes.Buffer, 0, _sharedProcesses)
+	for i := 0; i < _sharedProcesses; i++ {
+		out := &bytes.Buffer{}
+		cmd := exec.Command(os.Args[0], "-test.run=^Test_sharedRotationHelper$")
+		cmd.Env = append(os.Environ(), _sharedHelperFileEnv+"="+fp, _sharedHelperIDEnv+"="+strconv.Itoa(i))
+		cmd.Stdout = out
+		cmd.Stderr = out
+		require.NoError(t, cmd.Start())
+		cmds = append(cmds, cmd)
+		outputs = append(outputs, out)
+	}
+	for i, cmd := range cmds {
+		require.NoError(t, cmd.Wait(), outputs[i].String())
+	}
+
+	// exactly one process renamed the file.
+	rotated, err := filepath.Glob(fp + "-*")
+	require.NoError(t, err)
+	require.Equal(t, []string{fp + "-20200102"}, rotated)
+
+	// no line is lost or duplicated, and the lines after rotating are
+	// always written into the new file.
+	seen := make(map[string]bool, _sharedProcesses*_sharedLines)
+	for _, name := range []string{fp, rotated[0]} {
+		f, err := os.Open(name)
+		require.NoError(t, err)
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := scanner.Text()
+			assert.False(t, seen[line], "duplicated line: %s", line)
+			seen[line] = true
+
+			var id, n int
+			_, err = fmt.Sscanf(line, "id=%d n=%d", &id, &n)
+			require.NoError(t, err, line)
+			if n >= _sharedLines/2 {
+				assert.Equal(t, fp, name, "line after rotating: %s", line)
+			}
+		}
+		_ = f.Close()
+	}
+	assert.Len(t, seen, _sharedProcesses*_sharedLines)
+}
+
+func Test_fileWriter_sharedReopenOnWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-shared")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fp := filepath.Join(dir, "app.log")
+
+	w, err := newFileWriter(fp, false, &fileOptions{fsync: FsyncNever(), shared: true})
+	require.NoError(t, err)
+	defer w.Close()
+
+	// another process renamed the file.
+	require.NoError(t, os.Rename(fp, fp+"-old"))
+	w.lastCheck = time.Time{}
+	_, err = w.Write([]byte("after renamed\n"))
+	require.NoError(t, err)
+
+	data, err := ioutil.ReadFile(fp)
+	require.NoError(t, err)
+	assert.True(t, strings.Contains(string(data), "after renamed"))
+}
+
+func Test_fileWriter_sharedWithoutSuffix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-shared")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fp := filepath.Join(dir, "app")
+
+	w, err := newFileWriter(fp, false, &fileOptions{fsync: FsyncNever(), shared: true})
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Write([]byte("one\n"))
+	require.NoError(t, err)
+	w.lastCheck = time.Time{}
+	_, err = w.Write([]byte("two\n"))
+	require.NoError(t, err)
+
+	data, err := ioutil.ReadFile(fp)
+	require.NoError(t, err)
+	assert.Equal(t, "one\ntwo\n", string(data))
+	_, err = os.Stat(fp + ".log")
+	assert.True(t, os.IsNotExist(err), "the file without suffix is written only")
+
+	w.lastSplit = _sharedRotationDate
+	require.NoError(t, w.rotate())
+	_, err = w.Write([]byte("three\n"))
+	require.NoError(t, err)
+
+	data, err = ioutil.ReadFile(fp + "-20200102")
+	require.NoError(t, err)
+	assert.Equal(t, "one\ntwo\n", string(data))
+	data, err = ioutil.ReadFile(fp)
+	require.NoError(t, err)
+	assert.Equal(t, "three\n", string(data))
+}
@@ -0,0 +1,119 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// fileRegistry keeps the opened fileWriters by absolute path, so that the
+// Loggers and FileRouters in one process which write into the same file
+// share one fd and one rotating goroutine, instead of renaming the file
+// twice. fileWriter serializes writes, so lines would never interleave.
+type fileRegistry struct {
+	mu    sync.Mutex
+	files map[string]*registeredFile
+}
+
+// registeredFile is a fileWriter with its reference count.
+type registeredFile struct {
+	w          *fileWriter
+	autoRotate bool
+	opt        fileOptions
+	refs       int
+}
+
+var _files = &fileRegistry{files: make(map[string]*registeredFile, 4)}
+
+// acquire returns a handle of the fileWriter of abs, the fileWriter would be
+// opened if it's not opened yet. The options must be the same as the first
+// acquirer's, since they could not be applied to an opened file.
+func (r *fileRegistry) acquire(abs string, autoRotate bool, fo *fileOptions) (*fileHandle, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, ok := r.files[abs]
+	if ok {
+		if f.autoRotate != autoRotate || f.opt != *fo {
+			return nil, errors.Errorf("file %s has been opened with different options", abs)
+		}
+		f.refs++
+		return &fileHandle{fileWriter: f.w, abs: abs, registry: r}, nil
+	}
+
+	w, err := newFileWriter(abs, autoRotate, fo)
+	if err != nil {
+		return nil, err
+	}
+	r.files[abs] = &registeredFile{w: w, autoRotate: autoRotate, opt: *fo, refs: 1}
+
+	return &fileHandle{fileWriter: w, abs: abs, registry: r}, nil
+}
+
+// release decreases the reference count of abs, and closes the fileWriter
+// if it's the last reference.
+func (r *fileRegistry) release(abs string) error {
+	r.mu.Lock()
+	f, ok := r.files[abs]
+	if !ok {
+		r.mu.Unlock()
+		return nil
+	}
+	if f.refs--; f.refs > 0 {
+		r.mu.Unlock()
+		return nil
+	}
+	delete(r.files, abs)
+	r.mu.Unlock()
+
+	return f.w.Close()
+}
+
+// fileHandle is a reference to the shared fileWriter, closing the handle
+// only releases the reference.
+type fileHandle struct {
+	*fileWriter
+
+	abs      string
+	registry *fileRegistry
+	closed   int32
+}
+
+var _ loggerBinder = &fileHandle{}
+
+// bind reports the errors of background fsync to the ErrorHandler of l, each
+// Logger sharing the fileWriter gets them by its own handle.
+func (h *fileHandle) bind(l *Logger) {
+	h.fileWriter.onError(h, func(err error) {
+		l.handleError(ErrorKindSync, err)
+	})
+}
+
+func (h *fileHandle) Write(p []byte) (int, error) {
+	if atomic.LoadInt32(&h.closed) == 1 {
+		return 0, errWriterClosed
+	}
+
+	return h.fileWriter.Write(p)
+}
+
+// Sync commits the shared file to stable storage.
+func (h *fileHandle) Sync() error {
+	if atomic.LoadInt32(&h.closed) == 1 {
+		return nil
+	}
+
+	return h.fileWriter.Sync()
+}
+
+// Close releases the reference, the file would be closed after all handles
+// have been closed. It's safe to be called more than once.
+func (h *fileHandle) Close() error {
+	if !atomic.CompareAndSwapInt32(&h.closed, 0, 1) {
+		return nil
+	}
+
+	h.fileWriter.onError(h, nil)
+	return h.registry.release(h.abs)
+}
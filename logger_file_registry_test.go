@@ -0,0 +1,127 @@
+package log
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_fileRegistry_shared(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-registry")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fp := filepath.Join(dir, "app.log")
+
+	l1, err := NewLogger(WithFileLog(fp, true))
+	require.NoError(t, err)
+	l2, err := NewLogger(WithFileLog(fp, true))
+	require.NoError(t, err)
+
+	h1 := l1.opt.outputs[0].(*fileHandle)
+	h2 := l2.opt.outputs[0].(*fileHandle)
+	assert.Same(t, h1.fileWriter, h2.fileWriter)
+
+	// lines from two loggers never interleave.
+	var wg sync.WaitGroup
+	for _, l := range []*Logger{l1, l2} {
+		wg.Add(1)
+		go func(l *Logger) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				l.WithField("i", strconv.Itoa(i)).Info("shared file")
+			}
+		}(l)
+	}
+	wg.Wait()
+
+	// closing one logger keeps the file opened for the other.
+	require.NoError(t, l1.Close())
+	l2.Info("still writing")
+	require.NoError(t, l2.Close())
+	_, ok := _files.files[fp]
+	assert.False(t, ok)
+
+	f, err := os.Open(fp)
+	require.NoError(t, err)
+	defer f.Close()
+	line := regexp.MustCompile(`^\[INF\] "\d+" (Fields\{i="\d+"\} shared file|still writing)$`)
+	scanner := bufio.NewScanner(f)
+	count := 0
+	for scanner.Scan() {
+		assert.Regexp(t, line, scanner.Text())
+		count++
+	}
+	assert.Equal(t, 401, count)
+}
+
+func Test_fileRegistry_releaseReplaced(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-registry")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fp := filepath.Join(dir, "app.log")
+
+	// the file is replaced by a later writer.
+	l, err := NewLogger(WithFileLog(fp, true), WithCustomWriter(ioutil.Discard))
+	require.NoError(t, err)
+	_files.mu.Lock()
+	_, ok := _files.files[fp]
+	_files.mu.Unlock()
+	assert.False(t, ok, "released when replaced")
+	require.NoError(t, l.Close())
+
+	// a later option fails.
+	_, err = NewLogger(WithFileLog(fp, true), WithScopeMaxEntries(0))
+	require.Error(t, err)
+	_files.mu.Lock()
+	_, ok = _files.files[fp]
+	_files.mu.Unlock()
+	assert.False(t, ok, "released when failed")
+}
+
+func Test_fileRegistry_conflict(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-registry")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fp := filepath.Join(dir, "app.log")
+
+	l, err := NewLogger(WithFileLog(fp, false))
+	require.NoError(t, err)
+	defer l.Close()
+
+	_, err = NewLogger(WithFileLog(fp, true))
+	assert.Error(t, err)
+	_, err = NewLogger(WithFileLog(fp, false, WithFsync(FsyncEveryN(2))))
+	assert.Error(t, err)
+}
+
+func Test_fileHandle_Close(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-registry")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fp := filepath.Join(dir, "app.log")
+
+	h1, err := _files.acquire(fp, false, defaultFileOptions())
+	require.NoError(t, err)
+	h2, err := _files.acquire(fp, false, defaultFileOptions())
+	require.NoError(t, err)
+
+	// closing twice only releases once.
+	require.NoError(t, h1.Close())
+	require.NoError(t, h1.Close())
+	_, err = h1.Write([]byte("x"))
+	assert.Equal(t, errWriterClosed, err)
+
+	_, err = h2.Write([]byte("x"))
+	assert.NoError(t, err)
+	require.NoError(t, h2.Close())
+	_, err = h2.fileWriter.Write([]byte("x"))
+	assert.Equal(t, errWriterClosed, err)
+}
@@ -0,0 +1,149 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFileLogger(t *testing.T, policy FsyncPolicy) (*Logger, *fileWriter) {
+	dir, err := ioutil.TempDir("", "log-fsync")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	l, err := NewLogger(WithFileLog(filepath.Join(dir, "app.log"), false, WithFsync(policy)))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+
+	return l, l.opt.outputs[0].(*fileHandle).fileWriter
+}
+
+func (w *fileWriter) state() (dirty bool, pending int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.dirty, w.pending
+}
+
+func Test_FsyncEveryN(t *testing.T) {
+	l, w := newTestFileLogger(t, FsyncEveryN(3))
+
+	l.Info("1")
+	l.Info("2")
+	dirty, pending := w.state()
+	assert.True(t, dirty)
+	assert.Equal(t, 2, pending)
+
+	l.Info("3")
+	dirty, pending = w.state()
+	assert.False(t, dirty)
+	assert.Equal(t, 0, pending)
+}
+
+func Test_FsyncOnLevel(t *testing.T) {
+	l, w := newTestFileLogger(t, FsyncOnLevel(LevelError))
+
+	l.Warn("not synced")
+	dirty, _ := w.state()
+	assert.True(t, dirty)
+
+	l.Error("synced")
+	dirty, _ = w.state()
+	assert.False(t, dirty)
+}
+
+func Test_FsyncInterval(t *testing.T) {
+	l, w := newTestFileLogger(t, FsyncInterval(10*time.Millisecond))
+	assert.Empty(t, l.opt.committers)
+
+	l.Info("synced later")
+	assert.Eventually(t, func() bool {
+		dirty, _ := w.state()
+		return !dirty
+	}, time.Second, 5*time.Millisecond)
+}
+
+func Test_FsyncInterval_errors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-fsync")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fp := filepath.Join(dir, "app.log")
+	kinds := make(chan ErrorKind, 16)
+	handler := func(kind ErrorKind, err error) {
+		select {
+		case kinds <- kind:
+		default:
+		}
+	}
+	l1, err := NewLogger(WithFileLog(fp, false, WithFsync(FsyncInterval(10*time.Millisecond))), WithErrorHandler(handler))
+	require.NoError(t, err)
+	l2, err := NewLogger(WithFileLog(fp, false, WithFsync(FsyncInterval(10*time.Millisecond))), WithErrorHandler(nil))
+	require.NoError(t, err)
+
+	// break the shared file, so that the background fsync fails.
+	w := l1.opt.outputs[0].(*fileHandle).fileWriter
+	w.mu.Lock()
+	require.NoError(t, w.fd.Close())
+	w.dirty = true
+	w.mu.Unlock()
+
+	assert.Equal(t, ErrorKindSync, <-kinds)
+	assert.Eventually(t, func() bool {
+		return l1.ErrorStats().SyncErrors != 0 && l2.ErrorStats().SyncErrors != 0
+	}, time.Second, 5*time.Millisecond, "reported to every Logger sharing the file")
+
+	// the closed Logger is not reported any more.
+	_ = l1.Close()
+	w.mu.Lock()
+	n := len(w.onErrors)
+	w.mu.Unlock()
+	assert.Equal(t, 1, n)
+	_ = l2.Close()
+}
+
+func Test_FsyncNever(t *testing.T) {
+	l, w := newTestFileLogger(t, FsyncNever())
+	assert.Empty(t, l.opt.committers)
+
+	l.Error("never synced")
+	dirty, _ := w.state()
+	assert.True(t, dirty)
+}
+
+func Test_WithFsync_invalid(t *testing.T) {
+	_, err := NewLogger(WithFileLog(filepath.Join(os.TempDir(), "app.log"), false, WithFsync(FsyncEveryN(0))))
+	assert.Error(t, err)
+
+	_, err = NewLogger(WithFileLog(filepath.Join(os.TempDir(), "app.log"), false, WithFsync(FsyncInterval(0))))
+	assert.Error(t, err)
+}
+
+func Test_fileWriter_rotateWithoutSuffix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-rotate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fp := filepath.Join(dir, "app")
+
+	w, err := newFileWriter(fp, false, defaultFileOptions())
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Write([]byte("before\n"))
+	require.NoError(t, err)
+	w.lastSplit = time.Date(2020, 1, 2, 0, 0, 0, 0, time.Local)
+	require.NoError(t, w.rotate())
+	_, err = w.Write([]byte("after\n"))
+	require.NoError(t, err)
+
+	data, err := ioutil.ReadFile(fp + "-20200102")
+	require.NoError(t, err)
+	assert.Equal(t, "before\n", string(data))
+	data, err = ioutil.ReadFile(fp)
+	require.NoError(t, err)
+	assert.Equal(t, "after\n", string(data))
+}
@@ -0,0 +1,635 @@
+package log
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// _maxFilterDepth limits nesting of parentheses and '!' in filter expression.
+const _maxFilterDepth = 64
+
+// Filter is a compiled filter expression which matches entries by level,
+// message, caller and fields, such as:
+//
+//	level >= warn && fields.component == "db" && !(msg ~ "healthcheck")
+//
+// The grammar:
+//
+//	expr       = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | "(" expr ")" | comparison | "fields.<key>" | "true" | "false"
+//	comparison = selector op literal
+//	selector   = "level" | "msg" | "caller" | "fields.<key>"
+//	op         = "==" | "!=" | "<" | "<=" | ">" | ">=" | "~" | "!~"
+//	literal    = string in double quotes | number | "true" | "false" | level name
+//
+// Levels are compared by severity, so `level >= warn` matches Warning, Error
+// and Fatal entries, level names are fatal, error, warn (warning), info and
+// debug. `~` and `!~` match regular expressions. `caller` is `file:line`, it
+// is empty unless the logger reports caller. A single `fields.<key>` tests the
+// existence of field key, and a missing field only matches `!=` and `!~`.
+// Fields are compared as numbers with a number literal, as booleans with
+// true or false, and as their string forms with a string literal.
+type Filter struct {
+	expr string
+	root filterNode
+}
+
+var _ Processor = &Filter{}
+
+// ParseFilter compiles expr into Filter, the error indicates the offset of
+// invalid token in expr.
+func ParseFilter(expr string) (*Filter, error) {
+	toks, err := lexFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &filterParser{expr: expr, toks: toks}
+	if p.peek().kind == filterEOF {
+		return nil, errors.Errorf("invalid filter %q: empty expression", expr)
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != filterEOF {
+		return nil, p.errorf(tok, "unexpected %s", tok)
+	}
+
+	return &Filter{expr: expr, root: root}, nil
+}
+
+// MustParseFilter is like ParseFilter but panics if expr is invalid.
+func MustParseFilter(expr string) *Filter {
+	f, err := ParseFilter(expr)
+	if err != nil {
+		panic(err)
+	}
+
+	return f
+}
+
+// Match reports whether e matches the filter.
+func (f *Filter) Match(e *Entry) bool {
+	return f.root.eval(e)
+}
+
+// Process keeps the entries those match the filter, so Filter could be used
+// by WithProcessors, and DropIf(f.Match) drops them instead.
+func (f *Filter) Process(e *Entry) bool {
+	return f.Match(e)
+}
+
+// String returns the expression of filter.
+func (f *Filter) String() string {
+	return f.expr
+}
+
+type filterTokenKind uint8
+
+const (
+	filterEOF filterTokenKind = iota
+	filterIdent
+	filterString
+	filterNumber
+	filterOperator // comparison operators
+	filterAnd
+	filterOr
+	filterNot
+	filterLParen
+	filterRParen
+)
+
+type filterToken struct {
+	kind filterTokenKind
+	text string // raw text, strings are quoted
+	pos  int    // offset in expression
+}
+
+func (t filterToken) String() string {
+	if t.kind == filterEOF {
+		return "end of expression"
+	}
+
+	return "'" + t.text + "'"
+}
+
+func isFilterIdentChar(c byte) bool {
+	return c == '_' || c == '.' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// lexFilter splits expr into tokens, the last one is always filterEOF.
+func lexFilter(expr string) ([]filterToken, error) {
+	var toks []filterToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		start := i
+		two := ""
+		if i+1 < len(expr) {
+			two = expr[i : i+2]
+		}
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c == '(':
+			toks = append(toks, filterToken{kind: filterLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			toks = append(toks, filterToken{kind: filterRParen, text: ")", pos: i})
+			i++
+		case two == "&&":
+			toks = append(toks, filterToken{kind: filterAnd, text: two, pos: i})
+			i += 2
+		case two == "||":
+			toks = append(toks, filterToken{kind: filterOr, text: two, pos: i})
+			i += 2
+		case two == "==" || two == "!=" || two == "<=" || two == ">=" || two == "!~":
+			toks = append(toks, filterToken{kind: filterOperator, text: two, pos: i})
+			i += 2
+		case c == '<' || c == '>' || c == '~':
+			toks = append(toks, filterToken{kind: filterOperator, text: string(c), pos: i})
+			i++
+		case c == '!':
+			toks = append(toks, filterToken{kind: filterNot, text: "!", pos: i})
+			i++
+		case c == '"':
+			for i++; i < len(expr) && expr[i] != '"'; i++ {
+				if expr[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(expr) {
+				return nil, errors.Errorf("invalid filter %q: unterminated string at offset %d", expr, start)
+			}
+			i++
+			toks = append(toks, filterToken{kind: filterString, text: expr[start:i], pos: start})
+		case isDigit(c) || (c == '-' || c == '.') && i+1 < len(expr) && isDigit(expr[i+1]):
+			for i++; i < len(expr) && (isFilterIdentChar(expr[i]) || expr[i] == '+' || expr[i] == '-'); i++ {
+			}
+			toks = append(toks, filterToken{kind: filterNumber, text: expr[start:i], pos: start})
+		case isFilterIdentChar(c):
+			for i++; i < len(expr) && isFilterIdentChar(expr[i]); i++ {
+			}
+			toks = append(toks, filterToken{kind: filterIdent, text: expr[start:i], pos: start})
+		case c == '=':
+			return nil, errors.Errorf("invalid filter %q: unexpected '=' at offset %d, use '==' instead", expr, i)
+		default:
+			return nil, errors.Errorf("invalid filter %q: unexpected character %q at offset %d", expr, c, i)
+		}
+	}
+
+	return append(toks, filterToken{kind: filterEOF, pos: len(expr)}), nil
+}
+
+// filterParser is a recursive descent parser of filter expression.
+type filterParser struct {
+	expr  string
+	toks  []filterToken
+	i     int
+	depth int
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.toks[p.i]
+}
+
+func (p *filterParser) next() filterToken {
+	tok := p.toks[p.i]
+	if tok.kind != filterEOF {
+		p.i++
+	}
+
+	return tok
+}
+
+func (p *filterParser) errorf(tok filterToken, format string, args ...interface{}) error {
+	return errors.Errorf("invalid filter %q: %s at offset %d", p.expr, fmt.Sprintf(format, args...), tok.pos)
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == filterOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == filterAnd {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	tok := p.next()
+	switch tok.kind {
+	case filterNot, filterLParen:
+		if p.depth++; p.depth > _maxFilterDepth {
+			return nil, p.errorf(tok, "expression is nested too deeply")
+		}
+		defer func() { p.depth-- }()
+
+		if tok.kind == filterNot {
+			x, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+			return notNode{x: x}, nil
+		}
+
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if end := p.next(); end.kind != filterRParen {
+			return nil, p.errorf(end, "expected ')' to close '(' at offset %d, got %s", tok.pos, end)
+		}
+		return x, nil
+	case filterIdent:
+		return p.parseSelector(tok)
+	}
+
+	return nil, p.errorf(tok, "unexpected %s", tok)
+}
+
+// parseSelector parses the comparison or existence test of selector tok.
+func (p *filterParser) parseSelector(tok filterToken) (filterNode, error) {
+	var key string
+	switch {
+	case tok.text == "true" || tok.text == "false":
+		return boolNode(tok.text == "true"), nil
+	case tok.text == "level", tok.text == "msg", tok.text == "caller":
+	case strings.HasPrefix(tok.text, "fields."):
+		if key = strings.TrimPrefix(tok.text, "fields."); key == "" {
+			return nil, p.errorf(tok, "empty field key")
+		}
+	default:
+		return nil, p.errorf(tok, "unknown identifier '%s', expected level, msg, caller or fields.<key>", tok.text)
+	}
+
+	opTok := p.peek()
+	if opTok.kind != filterOperator {
+		if key != "" {
+			return existsNode(key), nil
+		}
+		return nil, p.errorf(opTok, "expected comparison operator after '%s', got %s", tok.text, opTok)
+	}
+	p.next()
+	op := parseFilterOp(opTok.text)
+	lit := p.next()
+
+	switch tok.text {
+	case "level":
+		return p.parseLevelComparison(op, opTok, lit)
+	case "msg", "caller":
+		return p.parseStringComparison(tok.text, op, opTok, lit)
+	}
+
+	return p.parseFieldComparison(key, op, opTok, lit)
+}
+
+func (p *filterParser) parseLevelComparison(op filterOp, opTok, lit filterToken) (filterNode, error) {
+	if op == opMatch || op == opNotMatch {
+		return nil, p.errorf(opTok, "operator %s could not be applied to level", opTok)
+	}
+
+	name := lit.text
+	switch lit.kind {
+	case filterIdent:
+	case filterString:
+		name, _ = strconv.Unquote(lit.text)
+	default:
+		return nil, p.errorf(lit, "expected level name, got %s", lit)
+	}
+	lv, ok := parseLevelName(name)
+	if !ok {
+		return nil, p.errorf(lit, "unknown level %s, expected fatal, error, warn, info or debug", lit)
+	}
+
+	return levelNode{op: op, lv: lv}, nil
+}
+
+func (p *filterParser) parseStringComparison(sel string, op filterOp, opTok, lit filterToken) (filterNode, error) {
+	if lit.kind != filterString {
+		return nil, p.errorf(lit, "expected string to compare with %s, got %s", sel, lit)
+	}
+	if op != opEq && op != opNe && op != opMatch && op != opNotMatch {
+		return nil, p.errorf(opTok, "operator %s could not be applied to %s", opTok, sel)
+	}
+
+	s, err := p.unquote(lit)
+	if err != nil {
+		return nil, err
+	}
+	node := stringNode{caller: sel == "caller", op: op, s: s}
+	if op == opMatch || op == opNotMatch {
+		if node.re, err = p.compile(lit, s); err != nil {
+			return nil, err
+		}
+	}
+
+	return node, nil
+}
+
+func (p *filterParser) parseFieldComparison(key string, op filterOp, opTok, lit filterToken) (filterNode, error) {
+	node := fieldNode{key: key, op: op}
+	switch {
+	case lit.kind == filterString:
+		s, err := p.unquote(lit)
+		if err != nil {
+			return nil, err
+		}
+		node.lit = s
+		if op == opMatch || op == opNotMatch {
+			if node.re, err = p.compile(lit, s); err != nil {
+				return nil, err
+			}
+		}
+		return node, nil
+	case op == opMatch || op == opNotMatch:
+		return nil, p.errorf(lit, "expected regular expression in string, got %s", lit)
+	case lit.kind == filterNumber:
+		n, err := strconv.ParseFloat(lit.text, 64)
+		if err != nil {
+			return nil, p.errorf(lit, "invalid number %s", lit)
+		}
+		node.lit = n
+		return node, nil
+	case lit.kind == filterIdent && (lit.text == "true" || lit.text == "false"):
+		if op != opEq && op != opNe {
+			return nil, p.errorf(opTok, "operator %s could not be applied to boolean", opTok)
+		}
+		node.lit = lit.text == "true"
+		return node, nil
+	}
+
+	return nil, p.errorf(lit, "expected string, number or boolean, got %s", lit)
+}
+
+func (p *filterParser) unquote(lit filterToken) (string, error) {
+	s, err := strconv.Unquote(lit.text)
+	if err != nil {
+		return "", p.errorf(lit, "invalid string %s", lit)
+	}
+
+	return s, nil
+}
+
+func (p *filterParser) compile(lit filterToken, s string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return nil, p.errorf(lit, "invalid regular expression: %v", err)
+	}
+
+	return re, nil
+}
+
+// parseLevelName parses level name case-insensitively.
+func parseLevelName(name string) (Level, bool) {
+	switch strings.ToLower(name) {
+	case "fatal":
+		return LevelFatal, true
+	case "error":
+		return LevelError, true
+	case "warn", "warning":
+		return LevelWarning, true
+	case "info":
+		return LevelInfo, true
+	case "debug":
+		return LevelDebug, true
+	}
+
+	return 0, false
+}
+
+type filterOp uint8
+
+const (
+	opEq filterOp = iota
+	opNe
+	opLt
+	opLe
+	opGt
+	opGe
+	opMatch
+	opNotMatch
+)
+
+func parseFilterOp(s string) filterOp {
+	switch s {
+	case "!=":
+		return opNe
+	case "<":
+		return opLt
+	case "<=":
+		return opLe
+	case ">":
+		return opGt
+	case ">=":
+		return opGe
+	case "~":
+		return opMatch
+	case "!~":
+		return opNotMatch
+	}
+
+	return opEq
+}
+
+// compare applies op on the result of comparison c, which is negative,
+// zero or positive.
+func (op filterOp) compare(c int) bool {
+	switch op {
+	case opEq:
+		return c == 0
+	case opNe:
+		return c != 0
+	case opLt:
+		return c < 0
+	case opLe:
+		return c <= 0
+	case opGt:
+		return c > 0
+	case opGe:
+		return c >= 0
+	}
+
+	return false
+}
+
+// filterNode is a node of compiled filter expression.
+type filterNode interface {
+	eval(e *Entry) bool
+}
+
+type (
+	boolNode   bool
+	existsNode string
+	andNode    struct{ left, right filterNode }
+	orNode     struct{ left, right filterNode }
+	notNode    struct{ x filterNode }
+
+	levelNode struct {
+		op filterOp
+		lv Level
+	}
+
+	stringNode struct {
+		caller bool // compares caller instead of message
+		op     filterOp
+		s      string
+		re     *regexp.Regexp
+	}
+
+	fieldNode struct {
+		key string
+		op  filterOp
+		lit interface{} // string, float64 or bool
+		re  *regexp.Regexp
+	}
+)
+
+func (n boolNode) eval(*Entry) bool { return bool(n) }
+
+func (n existsNode) eval(e *Entry) bool {
+	_, ok := e.Fields[string(n)]
+	return ok
+}
+
+func (n andNode) eval(e *Entry) bool { return n.left.eval(e) && n.right.eval(e) }
+
+func (n orNode) eval(e *Entry) bool { return n.left.eval(e) || n.right.eval(e) }
+
+func (n notNode) eval(e *Entry) bool { return !n.x.eval(e) }
+
+// eval compares by severity, the more severe the greater.
+func (n levelNode) eval(e *Entry) bool {
+	return n.op.compare(int(n.lv) - int(e.Level))
+}
+
+func (n stringNode) eval(e *Entry) bool {
+	s := e.Message
+	if n.caller {
+		s = ""
+		if e.Caller != nil {
+			s = e.Caller.File + ":" + strconv.Itoa(e.Caller.Line)
+		}
+	}
+
+	switch n.op {
+	case opMatch:
+		return n.re.MatchString(s)
+	case opNotMatch:
+		return !n.re.MatchString(s)
+	}
+
+	return n.op.compare(strings.Compare(s, n.s))
+}
+
+func (n fieldNode) eval(e *Entry) bool {
+	v, ok := e.Fields[n.key]
+	if !ok {
+		return n.op == opNe || n.op == opNotMatch
+	}
+
+	switch lit := n.lit.(type) {
+	case float64:
+		f, ok := toFloat64(v)
+		if !ok {
+			return n.op == opNe
+		}
+		switch {
+		case f < lit:
+			return n.op.compare(-1)
+		case f > lit:
+			return n.op.compare(1)
+		case f == lit:
+			return n.op.compare(0)
+		}
+		// NaN is not equal to anything.
+		return n.op == opNe
+	case bool:
+		b, ok := v.(bool)
+		return (ok && b == lit) == (n.op == opEq)
+	case string:
+		s := fmt.Sprintf(_interfaceFormat, v)
+		switch n.op {
+		case opMatch:
+			return n.re.MatchString(s)
+		case opNotMatch:
+			return !n.re.MatchString(s)
+		}
+		return n.op.compare(strings.Compare(s, lit))
+	}
+
+	return false
+}
+
+// toFloat64 converts numbers and numeric strings into float64.
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	case fmt.Stringer:
+		f, err := strconv.ParseFloat(n.String(), 64)
+		return f, err == nil
+	}
+
+	return 0, false
+}
@@ -0,0 +1,44 @@
+//go:build go1.18
+// +build go1.18
+
+package log
+
+import (
+	"testing"
+)
+
+func FuzzParseFilter(f *testing.F) {
+	seeds := []string{
+		`level >= warn && fields.component == "db" && !(msg ~ "healthcheck")`,
+		`fields.latency > 1.5e3 || caller ~ "main\\.go"`,
+		`!(!(level == debug))`,
+		`fields.retry == true && fields.code != "42"`,
+		`msg == "unterminated`,
+		`(((`,
+		`level = info`,
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	entries := []*Entry{
+		{Level: LevelError, Message: "query failed", Fields: Fields{"component": "db", "latency": 120, "retry": true}},
+		{Level: LevelDebug},
+	}
+	f.Fuzz(func(t *testing.T, expr string) {
+		filter, err := ParseFilter(expr)
+		if err != nil {
+			if filter != nil {
+				t.Fatalf("non-nil filter with error: %v", err)
+			}
+			return
+		}
+
+		for _, e := range entries {
+			filter.Match(e)
+		}
+		if filter.String() != expr {
+			t.Fatalf("String() = %q, want %q", filter.String(), expr)
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package log
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Filter_Match(t *testing.T) {
+	db := &Entry{
+		Level:   LevelError,
+		Message: "query failed",
+		Fields:  Fields{"component": "db", "latency": 120, "retry": true, "code": "42"},
+		Caller:  &runtime.Frame{File: "/app/db.go", Line: 12},
+	}
+	health := &Entry{
+		Level:   LevelWarning,
+		Message: "GET /healthcheck is slow",
+		Fields:  Fields{"component": "db", "latency": 3.5},
+	}
+	debug := &Entry{Level: LevelDebug, Message: "debug"}
+
+	cases := []struct {
+		expr string
+		want [3]bool // db, health, debug
+	}{
+		{`level >= warn && fields.component == "db" && !(msg ~ "healthcheck")`, [3]bool{true, false, false}},
+		{`level >= warn`, [3]bool{true, true, false}},
+		{`level == "warning"`, [3]bool{false, true, false}},
+		{`level < info`, [3]bool{false, false, true}},
+		{`level != ERROR`, [3]bool{false, true, true}},
+		{`msg == "debug" || level == error`, [3]bool{true, false, true}},
+		{`msg !~ "^GET "`, [3]bool{true, false, true}},
+		{`caller ~ "db\\.go:12$"`, [3]bool{true, false, false}},
+		{`fields.latency > 100`, [3]bool{true, false, false}},
+		{`fields.latency <= 3.5`, [3]bool{false, true, false}},
+		{`fields.code == 42`, [3]bool{true, false, false}},
+		{`fields.code == "42"`, [3]bool{true, false, false}},
+		{`fields.retry == true`, [3]bool{true, false, false}},
+		{`fields.retry != true`, [3]bool{false, true, true}},
+		{`fields.retry`, [3]bool{true, false, false}},
+		{`!fields.component`, [3]bool{false, false, true}},
+		{`fields.component != "db"`, [3]bool{false, false, true}},
+		{`fields.component ~ "^d"`, [3]bool{true, true, false}},
+		{`true && !false`, [3]bool{true, true, true}},
+		{`(level == fatal || level == debug) && msg == "debug"`, [3]bool{false, false, true}},
+		{`level == fatal || level == debug && msg == "x"`, [3]bool{false, false, false}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			f, err := ParseFilter(c.expr)
+			require.NoError(t, err)
+			assert.Equal(t, c.expr, f.String())
+			assert.Equal(t, c.want, [3]bool{f.Match(db), f.Match(health), f.Match(debug)})
+		})
+	}
+}
+
+func Test_ParseFilter_error(t *testing.T) {
+	cases := []struct {
+		expr string
+		err  string
+	}{
+		{``, "empty expression"},
+		{`level >= `, "expected level name, got end of expression at offset 9"},
+		{`level >= verbose`, "unknown level 'verbose'"},
+		{`level ~ "warn"`, "operator '~' could not be applied to level"},
+		{`lvl == info`, "unknown identifier 'lvl'"},
+		{`msg == 1`, "expected string to compare with msg"},
+		{`msg > "a"`, "operator '>' could not be applied to msg"},
+		{`msg ~ "("`, "invalid regular expression"},
+		{`msg == "unterminated`, "unterminated string at offset 7"},
+		{`level = info`, "use '==' instead"},
+		{`(level == info`, "expected ')' to close '(' at offset 0"},
+		{`level == info)`, "unexpected ')' at offset 13"},
+		{`level == info &&`, "unexpected end of expression"},
+		{`msg`, "expected comparison operator after 'msg'"},
+		{`fields. == 1`, "empty field key"},
+		{`fields.a > true`, "operator '>' could not be applied to boolean"},
+		{`fields.a ~ 1`, "expected regular expression in string"},
+		{`fields.a == 1.2.3`, "invalid number '1.2.3'"},
+		{`level == info # comment`, "unexpected character '#' at offset 14"},
+		{strings.Repeat("!", 100) + "true", "nested too deeply"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			_, err := ParseFilter(c.expr)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), c.err)
+		})
+	}
+
+	assert.Panics(t, func() { MustParseFilter("level") })
+}
+
+func Test_Filter_Processor(t *testing.T) {
+	sink := &syncSink{}
+	l, err := NewLogger(
+		WithCustomWriter(&strings.Builder{}),
+		WithSinks(sink),
+		WithProcessors(MustParseFilter(`level >= warn || fields.audit`)),
+	)
+	require.NoError(t, err)
+
+	l.Info("dropped")
+	l.WithFields(Fields{"audit": 1}).Info("kept")
+	l.Warn("kept")
+
+	assert.Equal(t, []string{"kept", "kept"}, sink.messages())
+}
@@ -0,0 +1,92 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// AllLevels contains all levels, it's handy for Hook.Levels.
+var AllLevels = []Level{LevelFatal, LevelError, LevelWarning, LevelInfo, LevelDebug}
+
+// Hook runs side effects on entries of certain levels, such as counting,
+// alerting or mirroring, without replacing the writer.
+type Hook interface {
+	// Levels returns the levels those the hook would be fired on.
+	Levels() []Level
+
+	// Fire is called synchronously in the logging goroutine after fields
+	// and context have been resolved, so it should not block for long.
+	// Entry is shared by hooks and sinks, it should not be modified.
+	Fire(e *Entry) error
+}
+
+// levelHooks are hooks indexed by level.
+type levelHooks map[Level][]Hook
+
+// hookSet keeps hooks in copy-on-write way, so that firing is lock-free
+// and hooks could be added while logging.
+type hookSet struct {
+	mu    sync.Mutex   // serializes add
+	list  []Hook       // hooks in order of adding, guarded by mu
+	hooks atomic.Value // levelHooks
+}
+
+func (s *hookSet) add(hooks ...Hook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, _ := s.hooks.Load().(levelHooks)
+	next := make(levelHooks, len(AllLevels))
+	for lv, hs := range prev {
+		next[lv] = append([]Hook(nil), hs...)
+	}
+	for _, hook := range hooks {
+		for _, lv := range hook.Levels() {
+			next[lv] = append(next[lv], hook)
+		}
+	}
+
+	s.list = append(s.list, hooks...)
+	s.hooks.Store(next)
+}
+
+// all returns every hook once no matter how many levels it's fired on.
+func (s *hookSet) all() []Hook {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Hook(nil), s.list...)
+}
+
+// get returns the hooks of lv, it must not be modified.
+func (s *hookSet) get(lv Level) []Hook {
+	hooks, _ := s.hooks.Load().(levelHooks)
+	return hooks[lv]
+}
+
+// WithHooks adds hooks those would be fired on their levels.
+func WithHooks(hooks ...Hook) LoggerOption {
+	return func(lo *options) error {
+		for _, hook := range hooks {
+			if hook == nil {
+				return errors.New("WithHooks: nil hook")
+			}
+		}
+		lo.hooks = append(lo.hooks, hooks...)
+		return nil
+	}
+}
+
+// AddHook adds hook into Logger, it's safe to be called while logging.
+func (l *Logger) AddHook(hook Hook) {
+	if hook == nil {
+		return
+	}
+
+	l.hooks.add(hook)
+	if b, ok := hook.(loggerBinder); ok {
+		b.bind(l)
+	}
+}
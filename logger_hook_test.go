@@ -0,0 +1,116 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordHook records the fired entries.
+type recordHook struct {
+	mu      sync.Mutex
+	levels  []Level
+	entries []*Entry
+	err     error
+}
+
+func (h *recordHook) Levels() []Level {
+	return h.levels
+}
+
+func (h *recordHook) Fire(e *Entry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.entries = append(h.entries, e)
+	return h.err
+}
+
+func (h *recordHook) fired() []*Entry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return append([]*Entry(nil), h.entries...)
+}
+
+type traceKey struct{}
+
+type traceParser struct{}
+
+func (traceParser) FieldName() string { return "trace" }
+
+func (traceParser) Parse(ctx context.Context) interface{} { return ctx.Value(traceKey{}) }
+
+func Test_Logger_WithHooks(t *testing.T) {
+	errHook := &recordHook{levels: []Level{LevelError, LevelFatal}}
+	allHook := &recordHook{levels: AllLevels}
+	l, err := NewLogger(
+		WithCustomWriter(&bytes.Buffer{}),
+		WithLevel(LevelInfo),
+		WithHooks(errHook, allHook),
+		WithGlobalFields(Fields{"app": "test"}),
+		WithContextParser(traceParser{}),
+	)
+	require.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), traceKey{}, "abc")
+	l.WithContext(ctx).WithFields(Fields{"k": "v"}).Error("failed")
+	l.Info("info")
+	l.Debug("filtered by level")
+
+	fired := errHook.fired()
+	require.Len(t, fired, 1)
+	assert.Equal(t, LevelError, fired[0].Level)
+	assert.Equal(t, "failed", fired[0].Message)
+	assert.Equal(t, Fields{"app": "test", "k": "v", "trace": "abc"}, fired[0].Fields)
+
+	assert.Len(t, allHook.fired(), 2)
+}
+
+func Test_Logger_AddHook(t *testing.T) {
+	l, err := NewLogger(WithCustomWriter(&bytes.Buffer{}))
+	require.NoError(t, err)
+
+	hook := &recordHook{levels: []Level{LevelWarning}}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			l.Warn("concurrent")
+		}
+	}()
+	l.AddHook(hook)
+	l.AddHook(nil)
+	wg.Wait()
+
+	l.Warn("after added")
+	fired := hook.fired()
+	require.NotEmpty(t, fired)
+	assert.Equal(t, "after added", fired[len(fired)-1].Message)
+}
+
+func Test_Logger_HookError(t *testing.T) {
+	var kinds []ErrorKind
+	hook := &recordHook{levels: AllLevels, err: errors.New("hook failed")}
+	l, err := NewLogger(
+		WithCustomWriter(&bytes.Buffer{}),
+		WithHooks(hook),
+		WithErrorHandler(func(kind ErrorKind, err error) {
+			kinds = append(kinds, kind)
+		}),
+	)
+	require.NoError(t, err)
+
+	l.Info("hello")
+	assert.Equal(t, []ErrorKind{ErrorKindHook}, kinds)
+	assert.Equal(t, uint64(1), l.ErrorStats().HookErrors)
+
+	_, err = NewLogger(WithHooks(nil))
+	assert.Error(t, err)
+}
@@ -0,0 +1,109 @@
+package log
+
+import (
+	"bytes"
+	"expvar"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+const _metricsLevels = LevelDebug + 1
+
+// Metrics is the snapshot of Logger's counters.
+type Metrics struct {
+	Entries      map[Level]uint64 // count of entries written into the writer successfully by level.
+	BytesWritten uint64           // bytes written into the writer successfully.
+	Dropped      uint64           // count of entries dropped by processors, full held buffers or full sink buffers.
+	WriteErrors  uint64           // count of writer errors.
+}
+
+// loggerMetrics are atomic counters of Metrics.
+type loggerMetrics struct {
+	entries [_metricsLevels]uint64
+	bytes   uint64
+	dropped uint64
+}
+
+func (m *loggerMetrics) incrEntry(lv Level) {
+	if lv < _metricsLevels {
+		atomic.AddUint64(&m.entries[lv], 1)
+	}
+}
+
+func (m *loggerMetrics) incrDropped() {
+	atomic.AddUint64(&m.dropped, 1)
+}
+
+// Metrics returns the snapshot of counters.
+func (l *Logger) Metrics() Metrics {
+	m := Metrics{
+		Entries:      make(map[Level]uint64, _metricsLevels),
+		BytesWritten: atomic.LoadUint64(&l.metrics.bytes),
+		Dropped:      atomic.LoadUint64(&l.metrics.dropped),
+		WriteErrors:  l.errCounters.stats().WriteErrors,
+	}
+	for lv := LevelFatal; lv < _metricsLevels; lv++ {
+		m.Entries[lv] = atomic.LoadUint64(&l.metrics.entries[lv])
+	}
+
+	return m
+}
+
+// PublishExpvar publishes the metrics as expvar of name, it would be served
+// in JSON by `/debug/vars` of expvar. It returns error if name has been used.
+func (l *Logger) PublishExpvar(name string) error {
+	if expvar.Get(name) != nil {
+		return errors.Errorf("PublishExpvar: %s has been published", name)
+	}
+
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		m := l.Metrics()
+		entries := make(map[string]uint64, len(m.Entries))
+		for lv, n := range m.Entries {
+			entries[lv.name()] = n
+		}
+
+		return map[string]interface{}{
+			"entries":       entries,
+			"bytes_written": m.BytesWritten,
+			"dropped":       m.Dropped,
+			"write_errors":  m.WriteErrors,
+		}
+	}))
+
+	return nil
+}
+
+// MetricsHandler returns a http.Handler serves the metrics in Prometheus text
+// exposition format, so they could be scraped without the Prometheus client.
+func (l *Logger) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = w.Write(l.formatPrometheus())
+	})
+}
+
+func (l *Logger) formatPrometheus() []byte {
+	m := l.Metrics()
+	buf := &bytes.Buffer{}
+
+	writePrometheusHeader(buf, "log_entries_total", "Count of entries written by level.")
+	for lv := LevelFatal; lv < _metricsLevels; lv++ {
+		fmt.Fprintf(buf, "log_entries_total{level=%q} %d\n", lv.name(), m.Entries[lv])
+	}
+	writePrometheusHeader(buf, "log_bytes_written_total", "Bytes written into the writer successfully.")
+	fmt.Fprintf(buf, "log_bytes_written_total %d\n", m.BytesWritten)
+	writePrometheusHeader(buf, "log_dropped_entries_total", "Count of entries dropped.")
+	fmt.Fprintf(buf, "log_dropped_entries_total %d\n", m.Dropped)
+	writePrometheusHeader(buf, "log_write_errors_total", "Count of writer errors.")
+	fmt.Fprintf(buf, "log_write_errors_total %d\n", m.WriteErrors)
+
+	return buf.Bytes()
+}
+
+func writePrometheusHeader(buf *bytes.Buffer, name, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+}
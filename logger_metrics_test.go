@@ -0,0 +1,137 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Logger_Metrics(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l, err := NewLogger(
+		WithCustomWriter(buf),
+		WithLevel(LevelInfo),
+		WithProcessors(DropIf(func(e *Entry) bool { return e.Message == "drop" })),
+	)
+	require.NoError(t, err)
+
+	l.Info("info")
+	l.Info("drop")
+	l.Warn("warning")
+	l.Error("error")
+	l.Debug("filtered by level")
+
+	m := l.Metrics()
+	assert.Equal(t, uint64(1), m.Entries[LevelInfo])
+	assert.Equal(t, uint64(1), m.Entries[LevelWarning])
+	assert.Equal(t, uint64(1), m.Entries[LevelError])
+	assert.Equal(t, uint64(0), m.Entries[LevelDebug])
+	assert.Equal(t, uint64(buf.Len()), m.BytesWritten)
+	assert.Equal(t, uint64(1), m.Dropped)
+	assert.Equal(t, uint64(0), m.WriteErrors)
+}
+
+func Test_Logger_Metrics_writeErrors(t *testing.T) {
+	l, err := NewLogger(WithCustomWriter(brokenWriter{}), WithErrorHandler(nil))
+	require.NoError(t, err)
+
+	l.Info("lost")
+
+	m := l.Metrics()
+	assert.Equal(t, uint64(0), m.Entries[LevelInfo])
+	assert.Equal(t, uint64(0), m.BytesWritten)
+	assert.Equal(t, uint64(1), m.WriteErrors)
+}
+
+func Test_Logger_Metrics_held(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l, err := NewLogger(
+		WithCustomWriter(buf),
+		WithLevel(LevelDebug),
+		WithFingersCrossed(LevelError, 2),
+		WithScopeMaxEntries(1),
+		WithSinks(SinkFunc(func(e *Entry) error {
+			if e.Message == "sink full" {
+				return errSinkBufferFull
+			}
+			return nil
+		})),
+		WithErrorHandler(nil),
+	)
+	require.NoError(t, err)
+
+	// held entries are not counted until they are written.
+	l.Debug("held 1")
+	l.Debug("held 2")
+	l.Debug("held 3")
+	assert.Equal(t, uint64(0), l.Metrics().Entries[LevelDebug])
+	assert.Equal(t, uint64(1), l.Metrics().Dropped, "discarded by fingers crossed")
+
+	l.Error("trigger")
+	m := l.Metrics()
+	assert.Equal(t, uint64(2), m.Entries[LevelDebug])
+	assert.Equal(t, uint64(1), m.Entries[LevelError])
+
+	// entries of a succeeded scope are discarded without being counted.
+	ctx, end := l.BeginScope(context.Background())
+	l.WithContext(ctx).Error("scoped 1")
+	l.WithContext(ctx).Error("scoped 2")
+	end(false)
+	m = l.Metrics()
+	assert.Equal(t, uint64(1), m.Entries[LevelError])
+	assert.Equal(t, uint64(2), m.Dropped, "discarded by the full scope")
+
+	l.Error("sink full")
+	m = l.Metrics()
+	assert.Equal(t, uint64(2), m.Entries[LevelError])
+	assert.Equal(t, uint64(3), m.Dropped, "dropped by the full sink")
+}
+
+func Test_Logger_MetricsHandler(t *testing.T) {
+	l, err := NewLogger(WithCustomWriter(&bytes.Buffer{}))
+	require.NoError(t, err)
+	l.Error("error")
+	l.Error("error")
+
+	rec := httptest.NewRecorder()
+	l.MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain; version=0.0.4")
+	body := rec.Body.String()
+	assert.Contains(t, body, "# TYPE log_entries_total counter\n")
+	assert.Contains(t, body, `log_entries_total{level="error"} 2`+"\n")
+	assert.Contains(t, body, `log_entries_total{level="info"} 0`+"\n")
+	assert.Contains(t, body, "log_dropped_entries_total 0\n")
+	assert.Contains(t, body, "log_write_errors_total 0\n")
+}
+
+func Test_Logger_PublishExpvar(t *testing.T) {
+	l, err := NewLogger(WithCustomWriter(&bytes.Buffer{}))
+	require.NoError(t, err)
+	l.Warn("warning")
+
+	// expvar names are process-global, so the name differs per run.
+	name := t.Name() + strconv.FormatInt(time.Now().UnixNano(), 10)
+	require.NoError(t, l.PublishExpvar(name))
+	err = l.PublishExpvar(name)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), name+" has been published")
+
+	var got struct {
+		Entries      map[string]uint64 `json:"entries"`
+		BytesWritten uint64            `json:"bytes_written"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(expvar.Get(name).String()), &got))
+	assert.Equal(t, uint64(1), got.Entries["warning"])
+	assert.Equal(t, l.Metrics().BytesWritten, got.BytesWritten)
+}
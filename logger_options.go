@@ -1,7 +1,6 @@
 package log
 
 import (
-	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -20,6 +19,11 @@ type options struct {
 	lv           Level     // only log.lv is lte than lv, then it would be written into Writer
 	globalFields Fields    // global fields
 
+	// outputs are writers merged into w, they are synced and closed by Logger.
+	outputs []io.Writer
+	// committers apply fsync policy after every entry has been written.
+	committers []committer
+
 	callerReporter bool          // log caller or not.
 	ctxParser      ContextParser // ContextParser for parse Context
 
@@ -30,6 +34,26 @@ type options struct {
 	// sortField print fields in order of fields' keys lexicographical order.
 	sortField bool
 
+	// sinks receive structured entries beside w.
+	sinks []Sink
+	// hooks would be added into Logger while constructing.
+	hooks []Hook
+	// processors transform entries before formatting.
+	processors []Processor
+	// recorder keeps the last entries of every level.
+	recorder *FlightRecorder
+	// crossed holds entries until an entry of trigger level arrives.
+	crossed *fingersCrossed
+	// scopeSlow is the threshold of slow scope, see BeginScope.
+	scopeSlow time.Duration
+	// scopeMaxEntries is the max count of entries held by one scope.
+	scopeMaxEntries int
+
+	// errorHandler handles errors occurred while outputting.
+	errorHandler ErrorHandler
+	// fallback would be written if w failed.
+	fallback io.Writer
+
 	// _isTerminal indicates the w is terminal or not, this is used for color output.
 	// Note that this is not a public field, it's used for internal,
 	// and it should be judged by isTerminal function.
@@ -52,15 +76,44 @@ func (o *options) writer() io.Writer {
 	return o.w
 }
 
+// setWriter replaces the writers by w, w would be locked if it's not safe
+// for concurrent use, see ConcurrentWriter. The files opened by WithFileLog
+// are released since they are replaced.
 func (o *options) setWriter(w io.Writer) {
 	if o == nil {
 		return
 	}
 
+	o.releaseFiles()
+	w = lockWriter(w)
 	o.w = w
+	o.outputs = []io.Writer{w}
+	o.committers = nil
 	o._isTerminal = isTerminal(w)
 }
 
+// releaseFiles releases the files opened by WithFileLog, the writers set by
+// WithCustomWriter are owned by the caller, so they are kept open.
+func (o *options) releaseFiles() {
+	for _, w := range o.outputs {
+		if h, ok := w.(*fileHandle); ok {
+			_ = h.Close()
+		}
+	}
+}
+
+// addWriter merges w with the previous writers by multiWriter, the writers
+// which route entries by fields like FileRouter still get fields.
+func (o *options) addWriter(w io.Writer) {
+	if o == nil {
+		return
+	}
+
+	o.outputs = append(o.outputs, lockWriter(w))
+	o.w = newMultiWriter(o.outputs...)
+	o._isTerminal = isTerminal(o.w)
+}
+
 // isTerminal indicates the w (io.Writer) is a byte output device.
 // TODO(@yeqown): caching judgement to reduce system call.
 func isTerminal(w io.Writer) bool {
@@ -96,6 +149,7 @@ func withDefault(lo *options) error {
 	lo.formatTime = false
 	lo.formatTimeLayout = ""
 	lo.sortField = false
+	lo.errorHandler = defaultErrorHandler
 
 	return nil
 }
@@ -113,8 +167,8 @@ func WithStdout(v bool) LoggerOption {
 	return func(lo *options) error {
 		if lo.w != nil && lo.w != os.Stdout {
 			// If lo.w has been set a writer, and the writer isn't os.Stdout,
-			// use io.MultiWriter to merge old writer and os.Stdout.
-			lo.setWriter(io.MultiWriter(lo.w, os.Stdout))
+			// use multiWriter to merge old writer and os.Stdout.
+			lo.addWriter(os.Stdout)
 		}
 		return nil
 	}
@@ -128,7 +182,11 @@ func WithGlobalFields(fields Fields) LoggerOption {
 	}
 }
 
-// WithCustomWriter using custom writer to log
+// WithCustomWriter using custom writer to log, writes into w would be
+// serialized by a lock unless w implements ConcurrentWriter. The lock belongs
+// to the Logger, so Loggers sharing one unsafe writer such as *bytes.Buffer
+// still race with each other; share one Logger by WithFields instead, or wrap
+// the writer with a lock and implement ConcurrentWriter.
 func WithCustomWriter(w io.Writer) LoggerOption {
 	return func(lo *options) error {
 		if w != nil {
@@ -161,54 +219,32 @@ func WithTimeFormat(b bool, layout string) LoggerOption {
 }
 
 // WithFileLog store log into file, if autoRotate is set, it will start a
-// goroutine to split log file by day.
-// TODO(@yeqown): using time round instead of ticker
-func WithFileLog(fp string, autoRotate bool) LoggerOption {
+// goroutine to split log file by day. opts customize the file writer, such
+// as WithFsync. Loggers those write into the same file share one writer,
+// so they must use the same autoRotate and opts.
+func WithFileLog(fp string, autoRotate bool, opts ...FileOption) LoggerOption {
 	return func(lo *options) error {
+		fo := defaultFileOptions()
+		for _, opt := range opts {
+			if err := opt(fo); err != nil {
+				return errors.Wrap(err, "WithFileLog: failed to apply file option")
+			}
+		}
+
 		// open file and set as writer
 		abs, err := filepath.Abs(fp)
 		if err != nil {
 			return errors.Wrapf(err, "WithFileLog.Abs fp: %s", fp)
 		}
-		dir, pureFilename := filepath.Split(abs)
-		fd, err2 := open(abs)
-		if err2 != nil {
-			return errors.Wrapf(err2, "WithFileLog.open abs: %s", abs)
+		w, err := _files.acquire(abs, autoRotate, fo)
+		if err != nil {
+			return errors.Wrapf(err, "WithFileLog.open abs: %s", abs)
 		}
-		lo.setWriter(fd)
-
-		// judge whether auto rotate enabled or not, if not enabled, return here.
-		if !autoRotate {
-			return nil
+		lo.setWriter(w)
+		if fo.fsync.perEntry() {
+			lo.committers = append(lo.committers, w)
 		}
 
-		// start a new goroutine to split log file by day.
-		go func() {
-			ticker := time.NewTicker(1 * time.Minute)
-			for tick := range ticker.C {
-				if !shouldSplitByTime(tick) {
-					continue
-				}
-
-				// rename fp to old filename
-				if err = rename(dir, pureFilename); err != nil {
-					fmt.Printf("rename failed dir: %s, filename: %s err: %v \n", dir, pureFilename, err)
-					continue
-				}
-
-				// open new fp and reset writer.
-				fd, err = open(assembleFilename(dir, fp, true))
-				if err != nil {
-					fmt.Printf("open failed fp: %s, err: %v \n", assembleFilename(dir, fp, true), err)
-					continue
-				}
-				lo.setWriter(fd)
-
-				// record the splitting time
-				lastSplitTimestamp = time.Now()
-			}
-		}()
-
 		return nil
 	}
 }
@@ -229,3 +265,47 @@ func WithFieldsSort(sortField bool) LoggerOption {
 		return nil
 	}
 }
+
+// WithSinks appends sinks those receive every structured Entry beside the writer.
+func WithSinks(sinks ...Sink) LoggerOption {
+	return func(lo *options) error {
+		for _, sink := range sinks {
+			if sink == nil {
+				return errors.New("WithSinks: nil sink")
+			}
+		}
+		lo.sinks = append(lo.sinks, sinks...)
+		return nil
+	}
+}
+
+// WithFlightRecorder keeps the last entries of every level in recorder,
+// including those are filtered by level.
+func WithFlightRecorder(recorder *FlightRecorder) LoggerOption {
+	return func(lo *options) error {
+		lo.recorder = recorder
+		return nil
+	}
+}
+
+// WithErrorHandler sets the handler of errors occurred while formatting,
+// writing and emitting into sinks. By default, errors are printed by standard
+// library log, nil handler means ignoring errors, they are counted anyway.
+func WithErrorHandler(h ErrorHandler) LoggerOption {
+	return func(lo *options) error {
+		lo.errorHandler = h
+		return nil
+	}
+}
+
+// WithFallbackWriter sets the writer which would be written if the writer
+// failed, os.Stderr is a common choice.
+func WithFallbackWriter(w io.Writer) LoggerOption {
+	return func(lo *options) error {
+		if w != nil {
+			w = lockWriter(w)
+		}
+		lo.fallback = w
+		return nil
+	}
+}
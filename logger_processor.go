@@ -0,0 +1,104 @@
+package log
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Processor transforms the Entry before it reaches the Formatter, it could
+// modify Level, Message and Fields in place, and returns false to drop the
+// entry. Processors are applied in order after the context was parsed, and
+// Fields of the Entry is a copy, so modifying it is safe.
+type Processor interface {
+	Process(e *Entry) bool
+}
+
+// ProcessorFunc is an adapter to allow the use of ordinary functions as Processor.
+type ProcessorFunc func(e *Entry) bool
+
+// Process calls f(e).
+func (f ProcessorFunc) Process(e *Entry) bool {
+	return f(e)
+}
+
+// WithProcessors appends processors into the processor chain of Logger.
+func WithProcessors(processors ...Processor) LoggerOption {
+	return func(lo *options) error {
+		for _, p := range processors {
+			if p == nil {
+				return errors.New("WithProcessors: nil processor")
+			}
+		}
+		lo.processors = append(lo.processors, processors...)
+		return nil
+	}
+}
+
+// process applies the processor chain on en, it returns false if en was
+// dropped by processor, or its level was remapped below the threshold.
+func process(processors []Processor, en *Entry, threshold Level) bool {
+	for _, p := range processors {
+		if !p.Process(en) {
+			return false
+		}
+	}
+
+	return en.Level <= threshold
+}
+
+// RenameKeys renames field keys by mapping from old key to new key.
+func RenameKeys(mapping map[string]string) Processor {
+	return ProcessorFunc(func(e *Entry) bool {
+		for from, to := range mapping {
+			v, ok := e.Fields[from]
+			if !ok {
+				continue
+			}
+			delete(e.Fields, from)
+			e.Fields[to] = v
+		}
+		return true
+	})
+}
+
+// AddStaticFields adds fields into every entry, the field which already
+// exists in entry would not be overwritten.
+func AddStaticFields(fields Fields) Processor {
+	return ProcessorFunc(func(e *Entry) bool {
+		for k, v := range fields {
+			if _, ok := e.Fields[k]; !ok {
+				e.Fields[k] = v
+			}
+		}
+		return true
+	})
+}
+
+// DropIf drops the entry if pred returns true.
+func DropIf(pred func(e *Entry) bool) Processor {
+	return ProcessorFunc(func(e *Entry) bool {
+		return !pred(e)
+	})
+}
+
+// RemapLevels changes the level of entry by mapping, such as downgrading a
+// noisy Error into Warning. The entry would be dropped if the new level is
+// below the level of Logger.
+func RemapLevels(mapping map[Level]Level) Processor {
+	return ProcessorFunc(func(e *Entry) bool {
+		if lv, ok := mapping[e.Level]; ok {
+			e.Level = lv
+		}
+		return true
+	})
+}
+
+// logDirect logs msg of lv with fields, it bypasses the level check and
+// processors, so that the summaries reported by processors would never be
+// filtered, sampled or limited.
+func (l *Logger) logDirect(lv Level, fields Fields, msg string) {
+	e := l.newEntry()
+	copyFields(e.fields, fields)
+	e.direct = true
+	e.output(lv, msg)
+	l.releaseEntry(e)
+}
@@ -0,0 +1,69 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Logger_WithProcessors(t *testing.T) {
+	buf := &bytes.Buffer{}
+	hook := &recordHook{levels: AllLevels}
+	l, err := NewLogger(
+		WithCustomWriter(buf),
+		WithLevel(LevelInfo),
+		WithFieldsSort(true),
+		WithHooks(hook),
+		WithProcessors(
+			RenameKeys(map[string]string{"uid": "user_id"}),
+			AddStaticFields(Fields{"region": "eu", "app": "default"}),
+			DropIf(func(e *Entry) bool { return strings.Contains(e.Message, "healthcheck") }),
+			RemapLevels(map[Level]Level{LevelError: LevelWarning, LevelWarning: LevelDebug}),
+			ProcessorFunc(func(e *Entry) bool {
+				e.Message = "processed: " + e.Message
+				return true
+			}),
+		),
+	)
+	require.NoError(t, err)
+
+	en := l.WithFields(Fields{"uid": 1, "app": "api"})
+	en.Error("failed")
+	l.Warn("healthcheck ok")
+	l.Warn("downgraded below threshold")
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 1)
+	assert.True(t, strings.HasPrefix(lines[0], "[WRN]"), lines[0])
+	assert.True(t, strings.HasSuffix(lines[0], `Fields{app="api" region="eu" user_id="1"} processed: failed`), lines[0])
+
+	fired := hook.fired()
+	require.Len(t, fired, 1)
+	assert.Equal(t, LevelWarning, fired[0].Level)
+	assert.Equal(t, "processed: failed", fired[0].Message)
+
+	// the fields of reused entry are not touched by processors.
+	assert.Equal(t, Fields{"uid": 1, "app": "api"}, en.fields)
+}
+
+func Test_process(t *testing.T) {
+	en := &Entry{Level: LevelError, Message: "m", Fields: Fields{"a": 1}}
+	remap := RemapLevels(map[Level]Level{LevelError: LevelDebug})
+	assert.True(t, process([]Processor{remap}, en, LevelDebug))
+	assert.Equal(t, LevelDebug, en.Level)
+
+	en.Level = LevelError
+	assert.False(t, process([]Processor{remap}, en, LevelInfo))
+
+	drop := DropIf(func(e *Entry) bool { return e.Fields["a"] == 1 })
+	called := false
+	after := ProcessorFunc(func(e *Entry) bool { called = true; return true })
+	assert.False(t, process([]Processor{drop, after}, &Entry{Fields: Fields{"a": 1}}, LevelDebug))
+	assert.False(t, called)
+
+	_, err := NewLogger(WithProcessors(nil))
+	assert.Error(t, err)
+}
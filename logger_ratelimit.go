@@ -0,0 +1,326 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	_defaultRateLimitMaxKeys       = 10000
+	_defaultRateLimitFlushInterval = 10 * time.Second
+
+	_suppressedKey     = "suppressed"
+	_suppressedFromKey = "suppressed_from"
+	_suppressedToKey   = "suppressed_to"
+)
+
+// RateLimitOption to apply single function into `ro`.
+type RateLimitOption func(ro *rateLimitOptions) error
+
+type rateLimitOptions struct {
+	key           string
+	maxKeys       int
+	flushInterval time.Duration
+}
+
+// WithRateLimitKey limits entries per value of field key, such as `user_id`,
+// entries without the field share one bucket.
+func WithRateLimitKey(key string) RateLimitOption {
+	return func(ro *rateLimitOptions) error {
+		ro.key = key
+		return nil
+	}
+}
+
+// WithRateLimitMaxKeys sets the max count of buckets those would be kept, to
+// bound the memory while limiting by field key.
+func WithRateLimitMaxKeys(n int) RateLimitOption {
+	return func(ro *rateLimitOptions) error {
+		if n <= 0 {
+			return errors.Errorf("WithRateLimitMaxKeys: n must be positive, got %d", n)
+		}
+		ro.maxKeys = n
+		return nil
+	}
+}
+
+// WithRateLimitFlushInterval sets the interval to report the suppressed
+// entries of keys those have not been let through again, 10s by default.
+func WithRateLimitFlushInterval(d time.Duration) RateLimitOption {
+	return func(ro *rateLimitOptions) error {
+		if d <= 0 {
+			return errors.Errorf("WithRateLimitFlushInterval: interval must be positive, got %s", d)
+		}
+		ro.flushInterval = d
+		return nil
+	}
+}
+
+// tokenBucket is the state of one key.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	hasKey bool // whether the entries have the field of key
+
+	suppressed      uint64
+	level           Level // the most severe level of suppressed entries
+	firstSuppressed time.Time
+	lastSuppressed  time.Time
+}
+
+// take refills the bucket and takes a token if there is.
+func (b *tokenBucket) take(now time.Time, rate float64, burst int) bool {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * rate
+		if b.tokens > float64(burst) {
+			b.tokens = float64(burst)
+		}
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+
+	return true
+}
+
+// RateLimiter is a Processor which limits entries by token bucket, per Logger
+// or per field value. Entries over the limit are suppressed, a summary entry
+// tells how many entries were suppressed and the time range they covered. It
+// is logged before the next entry of the key is let through, or every flush
+// interval, or when the Logger is synced or closed. The summary is logged at
+// the most severe level of suppressed entries, and it bypasses the level check
+// and processors.
+type RateLimiter struct {
+	opt   *rateLimitOptions
+	rate  float64
+	burst int
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	logger  *Logger
+
+	stop   chan struct{}
+	closed int32
+}
+
+var (
+	_ Processor    = &RateLimiter{}
+	_ loggerBinder = &RateLimiter{}
+)
+
+// NewRateLimiter creates a RateLimiter allows rate entries per second with
+// burst, it should be attached to one Logger by WithProcessors.
+func NewRateLimiter(rate float64, burst int, opts ...RateLimitOption) (*RateLimiter, error) {
+	if rate <= 0 || burst <= 0 {
+		return nil, errors.Errorf("NewRateLimiter: rate %v and burst %d must be positive", rate, burst)
+	}
+
+	ro := &rateLimitOptions{
+		maxKeys:       _defaultRateLimitMaxKeys,
+		flushInterval: _defaultRateLimitFlushInterval,
+	}
+	for _, opt := range opts {
+		if err := opt(ro); err != nil {
+			return nil, errors.Wrap(err, "failed to apply option")
+		}
+	}
+
+	return &RateLimiter{
+		opt:     ro,
+		rate:    rate,
+		burst:   burst,
+		buckets: make(map[string]*tokenBucket, 16),
+		stop:    make(chan struct{}),
+	}, nil
+}
+
+// bind attaches rl to l, and starts reporting suppressed entries periodically.
+func (rl *RateLimiter) bind(l *Logger) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if rl.logger == nil {
+		rl.logger = l
+		go rl.flushLoop()
+	}
+}
+
+// suppression is the summary of suppressed entries of one key.
+type suppression struct {
+	key      string
+	hasKey   bool
+	count    uint64
+	level    Level
+	from, to time.Time
+}
+
+// takeSuppression moves the suppressed entries of b into suppression, nil is returned
+// if there is none.
+func (b *tokenBucket) takeSuppression(key string, hasKey bool) *suppression {
+	if b.suppressed == 0 {
+		return nil
+	}
+
+	s := &suppression{
+		key:    key,
+		hasKey: hasKey,
+		count:  b.suppressed,
+		level:  b.level,
+		from:   b.firstSuppressed,
+		to:     b.lastSuppressed,
+	}
+	b.suppressed = 0
+
+	return s
+}
+
+// Process takes a token of e's key, e would be dropped if there is no token.
+// Fatal entries are never dropped.
+func (rl *RateLimiter) Process(e *Entry) bool {
+	if e.Level == LevelFatal {
+		return true
+	}
+
+	var (
+		key    string
+		hasKey bool
+	)
+	if rl.opt.key != "" {
+		if v, ok := e.Fields[rl.opt.key]; ok {
+			key, hasKey = fmt.Sprintf(_interfaceFormat, v), true
+		}
+	}
+
+	rl.mu.Lock()
+	b, evicted := rl.bucket(key, hasKey, e.Time)
+	if !b.take(e.Time, rl.rate, rl.burst) {
+		if b.suppressed == 0 || e.Level < b.level {
+			b.level = e.Level
+		}
+		if b.suppressed == 0 {
+			b.firstSuppressed = e.Time
+		}
+		b.suppressed++
+		b.lastSuppressed = e.Time
+		logger := rl.logger
+		rl.mu.Unlock()
+
+		rl.report(logger, evicted)
+		return false
+	}
+
+	s := b.takeSuppression(key, hasKey)
+	logger := rl.logger
+	rl.mu.Unlock()
+
+	rl.report(logger, evicted)
+	rl.report(logger, s)
+	return true
+}
+
+// bucket returns the bucket of key, the idle buckets would be evicted if
+// there are too many keys, the suppressed entries of the evicted one is
+// returned to be reported.
+func (rl *RateLimiter) bucket(key string, hasKey bool, now time.Time) (*tokenBucket, *suppression) {
+	if b, ok := rl.buckets[key]; ok {
+		return b, nil
+	}
+
+	var evicted *suppression
+	if len(rl.buckets) >= rl.opt.maxKeys {
+		evicted = rl.evict()
+	}
+	b := &tokenBucket{tokens: float64(rl.burst), last: now, hasKey: hasKey}
+	rl.buckets[key] = b
+
+	return b, evicted
+}
+
+// evict removes a bucket without suppressed entries, or any one if all
+// buckets are suppressing, and returns its suppressed entries.
+func (rl *RateLimiter) evict() *suppression {
+	victim, found := "", false
+	for k, b := range rl.buckets {
+		victim, found = k, true
+		if b.suppressed == 0 {
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	b := rl.buckets[victim]
+	delete(rl.buckets, victim)
+	return b.takeSuppression(victim, b.hasKey)
+}
+
+// flushLoop reports suppressed entries every flush interval.
+func (rl *RateLimiter) flushLoop() {
+	ticker := time.NewTicker(rl.opt.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-rl.stop:
+			return
+		case <-ticker.C:
+			_ = rl.Sync()
+		}
+	}
+}
+
+// Sync reports the suppressed entries of all keys.
+func (rl *RateLimiter) Sync() error {
+	rl.mu.Lock()
+	logger := rl.logger
+	if logger == nil {
+		rl.mu.Unlock()
+		return nil
+	}
+	var ss []*suppression
+	for k, b := range rl.buckets {
+		if s := b.takeSuppression(k, b.hasKey); s != nil {
+			ss = append(ss, s)
+		}
+	}
+	rl.mu.Unlock()
+
+	for _, s := range ss {
+		rl.report(logger, s)
+	}
+
+	return nil
+}
+
+// Close stops reporting periodically, and reports the suppressed entries.
+func (rl *RateLimiter) Close() error {
+	if atomic.CompareAndSwapInt32(&rl.closed, 0, 1) {
+		close(rl.stop)
+	}
+
+	return rl.Sync()
+}
+
+func (rl *RateLimiter) report(l *Logger, s *suppression) {
+	if l == nil || s == nil {
+		return
+	}
+
+	fields := Fields{
+		_suppressedKey:     s.count,
+		_suppressedFromKey: s.from.Format(time.RFC3339Nano),
+		_suppressedToKey:   s.to.Format(time.RFC3339Nano),
+	}
+	if s.hasKey {
+		fields[rl.opt.key] = s.key
+	}
+
+	l.logDirect(s.level, fields, fmt.Sprintf("suppressed %d messages", s.count))
+}
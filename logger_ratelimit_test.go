@@ -0,0 +1,181 @@
+package log
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// collectSink collects the emitted entries.
+func collectSink(entries *[]*Entry) Sink {
+	return SinkFunc(func(e *Entry) error {
+		*entries = append(*entries, e)
+		return nil
+	})
+}
+
+func Test_RateLimiter(t *testing.T) {
+	limiter, err := NewRateLimiter(10, 2)
+	require.NoError(t, err)
+
+	var entries []*Entry
+	l, err := NewLogger(WithCustomWriter(&safeBuffer{}), WithProcessors(limiter), WithSinks(collectSink(&entries)))
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		l.Info("flood")
+	}
+	require.Len(t, entries, 2)
+
+	// the limit is lifted after a token is refilled.
+	time.Sleep(120 * time.Millisecond)
+	l.Info("lifted")
+	require.Len(t, entries, 4)
+
+	summary := entries[2]
+	assert.Equal(t, LevelInfo, summary.Level, "at the most severe suppressed level")
+	assert.Equal(t, "suppressed 3 messages", summary.Message)
+	assert.Equal(t, uint64(3), summary.Fields[_suppressedKey])
+	from, err := time.Parse(time.RFC3339Nano, summary.Fields[_suppressedFromKey].(string))
+	require.NoError(t, err)
+	to, err := time.Parse(time.RFC3339Nano, summary.Fields[_suppressedToKey].(string))
+	require.NoError(t, err)
+	assert.False(t, to.Before(from))
+	assert.True(t, to.Before(entries[3].Time))
+	assert.Equal(t, "lifted", entries[3].Message)
+}
+
+func Test_RateLimiter_perKey(t *testing.T) {
+	limiter, err := NewRateLimiter(1, 1, WithRateLimitKey("user_id"))
+	require.NoError(t, err)
+
+	var entries []*Entry
+	_, err = NewLogger(WithCustomWriter(&safeBuffer{}), WithProcessors(limiter), WithSinks(collectSink(&entries)))
+	require.NoError(t, err)
+
+	now := time.Now()
+	entry := func(uid interface{}, sec int) *Entry {
+		fields := Fields{}
+		if uid != nil {
+			fields["user_id"] = uid
+		}
+		return &Entry{Level: LevelInfo, Message: "m", Fields: fields, Time: now.Add(time.Duration(sec) * time.Second)}
+	}
+
+	assert.True(t, limiter.Process(entry(1, 0)))
+	assert.False(t, limiter.Process(entry(1, 0)))
+	assert.True(t, limiter.Process(entry(2, 0)))
+	assert.True(t, limiter.Process(entry(nil, 0)))
+	assert.False(t, limiter.Process(entry(nil, 0)))
+	assert.Empty(t, entries)
+
+	assert.True(t, limiter.Process(entry(1, 1)))
+	require.Len(t, entries, 1)
+	assert.Equal(t, "suppressed 1 messages", entries[0].Message)
+	assert.Equal(t, "1", entries[0].Fields["user_id"])
+	assert.Equal(t, now.Format(time.RFC3339Nano), entries[0].Fields[_suppressedFromKey])
+}
+
+func Test_RateLimiter_maxKeys(t *testing.T) {
+	limiter, err := NewRateLimiter(1, 1, WithRateLimitKey("k"), WithRateLimitMaxKeys(2))
+	require.NoError(t, err)
+
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		limiter.Process(&Entry{Level: LevelInfo, Fields: Fields{"k": i}, Time: now})
+	}
+	assert.Len(t, limiter.buckets, 2)
+
+	// the suppressed entries of evicted bucket are reported.
+	var entries []*Entry
+	limiter, err = NewRateLimiter(1, 1, WithRateLimitKey("k"), WithRateLimitMaxKeys(1))
+	require.NoError(t, err)
+	_, err = NewLogger(WithCustomWriter(&safeBuffer{}), WithProcessors(limiter), WithSinks(collectSink(&entries)))
+	require.NoError(t, err)
+	limiter.Process(&Entry{Level: LevelInfo, Fields: Fields{"k": 1}, Time: now})
+	limiter.Process(&Entry{Level: LevelInfo, Fields: Fields{"k": 1}, Time: now})
+	assert.Empty(t, entries)
+	limiter.Process(&Entry{Level: LevelInfo, Fields: Fields{"k": 2}, Time: now})
+	require.Len(t, entries, 1)
+	assert.Equal(t, "1", entries[0].Fields["k"])
+
+	_, err = NewRateLimiter(0, 1)
+	assert.Error(t, err)
+}
+
+func Test_RateLimiter_reportOnClose(t *testing.T) {
+	limiter, err := NewRateLimiter(1, 1)
+	require.NoError(t, err)
+
+	var entries []*Entry
+	l, err := NewLogger(
+		WithCustomWriter(&safeBuffer{}),
+		WithLevel(LevelError),
+		WithProcessors(limiter, MustParseFilter(`level >= error && msg != "suppressed 4 messages"`)),
+		WithSinks(collectSink(&entries)),
+	)
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		l.Error("flood")
+	}
+	l.Warn("filtered")
+	require.Len(t, entries, 1)
+
+	// the key goes quiet, the summary is reported on Close.
+	require.NoError(t, l.Close())
+	require.Len(t, entries, 2)
+	assert.Equal(t, LevelError, entries[1].Level)
+	assert.Equal(t, "suppressed 4 messages", entries[1].Message)
+}
+
+func Test_RateLimiter_flushInterval(t *testing.T) {
+	limiter, err := NewRateLimiter(1, 1, WithRateLimitFlushInterval(20*time.Millisecond))
+	require.NoError(t, err)
+	defer limiter.Close()
+
+	sink := &syncSink{}
+	l, err := NewLogger(WithCustomWriter(&safeBuffer{}), WithProcessors(limiter), WithSinks(sink))
+	require.NoError(t, err)
+
+	l.Info("flood")
+	l.Warn("flood")
+	l.Info("flood")
+
+	assert.Eventually(t, func() bool {
+		msgs := sink.messages()
+		return len(msgs) == 2 && msgs[1] == "suppressed 2 messages"
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, LevelWarning, sink.entries[1].Level)
+
+	_, err = NewRateLimiter(1, 1, WithRateLimitFlushInterval(0))
+	assert.Error(t, err)
+}
+
+func Test_RateLimiter_fatal(t *testing.T) {
+	var code int
+	exit = func(c int) { code = c }
+	defer func() { exit = os.Exit }()
+
+	limiter, err := NewRateLimiter(0.001, 1)
+	require.NoError(t, err)
+
+	var entries []*Entry
+	l, err := NewLogger(WithCustomWriter(&safeBuffer{}), WithProcessors(limiter), WithSinks(collectSink(&entries)))
+	require.NoError(t, err)
+
+	l.Error("take the only token")
+	l.Error("suppressed")
+	l.Fatal("fatal")
+
+	assert.Equal(t, 1, code)
+	var messages []string
+	for _, e := range entries {
+		messages = append(messages, e.Message)
+	}
+	assert.Contains(t, messages, "fatal", "fatal entries are never limited")
+	require.NoError(t, l.Close())
+}
@@ -0,0 +1,164 @@
+package log
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const _defaultRecorderSize = 2000
+
+// FlightRecorder keeps the last N entries of every level in memory, including
+// those are filtered by the logger's level. It's helpful to find out what
+// happened before a crash, so it's dumped automatically when Fatal is called
+// or a panic is recovered by DumpOnPanic, and it could be dumped on demand by
+// Dump or as an http.Handler.
+type FlightRecorder struct {
+	mu      sync.Mutex
+	entries []*Entry // ring buffer
+	next    int      // next position to write
+	full    bool     // the ring buffer has been filled
+
+	w         io.Writer // destination of automatic dump
+	formatter Formatter
+}
+
+var _ http.Handler = &FlightRecorder{}
+
+// NewFlightRecorder creates a FlightRecorder keeps the last size entries, w is
+// the destination of automatic dump, os.Stderr would be used if w is nil.
+func NewFlightRecorder(size int, w io.Writer) *FlightRecorder {
+	if size <= 0 {
+		size = _defaultRecorderSize
+	}
+	if w == nil {
+		w = os.Stderr
+	}
+
+	return &FlightRecorder{
+		entries:   make([]*Entry, size),
+		w:         w,
+		formatter: newTextFormatter(false, true, true, time.RFC3339),
+	}
+}
+
+// record puts e into ring buffer, the oldest one would be overwritten.
+func (r *FlightRecorder) record(e *Entry) {
+	r.mu.Lock()
+	r.entries[r.next] = e
+	if r.next++; r.next == len(r.entries) {
+		r.next = 0
+		r.full = true
+	}
+	r.mu.Unlock()
+}
+
+// Entries returns the recorded entries from the oldest to the newest.
+func (r *FlightRecorder) Entries() []*Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.full {
+		out := make([]*Entry, r.next)
+		copy(out, r.entries[:r.next])
+		return out
+	}
+
+	out := make([]*Entry, 0, len(r.entries))
+	out = append(out, r.entries[r.next:]...)
+	return append(out, r.entries[:r.next]...)
+}
+
+// Reset drops all recorded entries.
+func (r *FlightRecorder) Reset() {
+	r.mu.Lock()
+	for i := range r.entries {
+		r.entries[i] = nil
+	}
+	r.next = 0
+	r.full = false
+	r.mu.Unlock()
+}
+
+// Dump writes the recorded entries into w in text format.
+func (r *FlightRecorder) Dump(w io.Writer) error {
+	entries := r.Entries()
+
+	header := "==== flight recorder: last " + strconv.Itoa(len(entries)) + " entries ====\n"
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		data, err := formatEntry(r.formatter, e)
+		if err != nil {
+			return err
+		}
+		if _, err = w.Write(data); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "==== flight recorder end ====\n")
+
+	return err
+}
+
+// DumpOnPanic should be deferred, it dumps the recorded entries into the
+// automatic dump destination if there is a panic, and then panics again.
+//
+//	defer recorder.DumpOnPanic()
+func (r *FlightRecorder) DumpOnPanic() {
+	if v := recover(); v != nil {
+		_ = r.Dump(r.w)
+		panic(v)
+	}
+}
+
+// dump dumps into the automatic dump destination.
+func (r *FlightRecorder) dump() {
+	_ = r.Dump(r.w)
+}
+
+// ServeHTTP dumps the recorded entries in text format, or in JSON if the
+// query `format=json` is specified.
+func (r *FlightRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Query().Get("format") != "json" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_ = r.Dump(w)
+		return
+	}
+
+	type jsonEntry struct {
+		Level   string          `json:"level"`
+		Message string          `json:"msg"`
+		Time    time.Time       `json:"time"`
+		Fields  json.RawMessage `json:"fields,omitempty"`
+		Caller  string          `json:"caller,omitempty"`
+	}
+
+	entries := r.Entries()
+	out := make([]jsonEntry, 0, len(entries))
+	for _, e := range entries {
+		je := jsonEntry{
+			Level:   e.Level.name(),
+			Message: e.Message,
+			Time:    e.Time,
+		}
+		if len(e.Fields) != 0 {
+			// marshalJSON modifies the map, so copy it.
+			fields := make(map[string]interface{}, len(e.Fields))
+			copyFields(fields, e.Fields)
+			je.Fields = marshalJSON(fields)
+		}
+		if e.Caller != nil {
+			je.Caller = e.Caller.File + ":" + strconv.Itoa(e.Caller.Line)
+		}
+		out = append(out, je)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
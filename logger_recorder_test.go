@@ -0,0 +1,104 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_FlightRecorder(t *testing.T) {
+	recorder := NewFlightRecorder(3, nil)
+	b := &bytes.Buffer{}
+	l, err := NewLogger(
+		WithCustomWriter(b),
+		WithLevel(LevelError),
+		WithFlightRecorder(recorder),
+	)
+	require.NoError(t, err)
+
+	l.Debug("debug 1")
+	assert.Empty(t, b.String())
+	require.Len(t, recorder.Entries(), 1)
+	assert.Equal(t, LevelDebug, recorder.Entries()[0].Level)
+
+	l.WithField("k", "v").Info("info 2")
+	l.Warn("warn 3")
+	l.Error("error 4")
+
+	// the oldest one has been overwritten.
+	entries := recorder.Entries()
+	require.Len(t, entries, 3)
+	assert.Equal(t, "info 2", entries[0].Message)
+	assert.Equal(t, Fields{"k": "v"}, entries[0].Fields)
+	assert.Equal(t, "warn 3", entries[1].Message)
+	assert.Equal(t, "error 4", entries[2].Message)
+
+	dump := &bytes.Buffer{}
+	require.NoError(t, recorder.Dump(dump))
+	assert.Contains(t, dump.String(), "last 3 entries")
+	assert.Contains(t, dump.String(), "[INF]")
+	assert.Contains(t, dump.String(), `k="v"`)
+	assert.Contains(t, dump.String(), "[ERR]")
+
+	recorder.Reset()
+	assert.Empty(t, recorder.Entries())
+}
+
+func Test_FlightRecorder_ServeHTTP(t *testing.T) {
+	recorder := NewFlightRecorder(10, nil)
+	l, err := NewLogger(
+		WithCustomWriter(ioutil.Discard),
+		WithFlightRecorder(recorder),
+	)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		l.WithField("i", i).Info("entry " + strconv.Itoa(i))
+	}
+
+	rec := httptest.NewRecorder()
+	recorder.ServeHTTP(rec, httptest.NewRequest("GET", "/?format=json", nil))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var out []map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &out))
+	require.Len(t, out, 3)
+	assert.Equal(t, "info", out[2]["level"])
+	assert.Equal(t, "entry 2", out[2]["msg"])
+	assert.Equal(t, map[string]interface{}{"i": float64(2)}, out[2]["fields"])
+
+	rec = httptest.NewRecorder()
+	recorder.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	assert.Contains(t, rec.Body.String(), "entry 1")
+}
+
+func Test_FlightRecorder_DumpOnPanic(t *testing.T) {
+	dump := &bytes.Buffer{}
+	recorder := NewFlightRecorder(10, dump)
+	l, err := NewLogger(
+		WithCustomWriter(ioutil.Discard),
+		WithLevel(LevelInfo),
+		WithFlightRecorder(recorder),
+	)
+	require.NoError(t, err)
+
+	assert.PanicsWithValue(t, "boom", func() {
+		defer recorder.DumpOnPanic()
+		l.Debug("before panic")
+		panic("boom")
+	})
+	assert.Contains(t, dump.String(), "before panic")
+
+	// nothing would be dumped without panic.
+	dump.Reset()
+	func() {
+		defer recorder.DumpOnPanic()
+	}()
+	assert.Empty(t, dump.String())
+}
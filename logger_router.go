@@ -0,0 +1,393 @@
+package log
+
+import (
+	"container/list"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	_defaultRouterMaxOpen     = 64
+	_defaultRouterIdleTimeout = 5 * time.Minute
+	_minRouterIdleTick        = time.Millisecond
+	_routerDefaultValue       = "default"
+)
+
+// fieldsWriter is implemented by writers which choose the destination by
+// fields of entry, output calls writeFields instead of Write for them.
+type fieldsWriter interface {
+	writeFields(fields Fields, p []byte) (int, error)
+}
+
+// FileRouterOption to apply single function into `ro`.
+type FileRouterOption func(ro *fileRouterOptions) error
+
+type fileRouterOptions struct {
+	maxOpen     int
+	idleTimeout time.Duration
+	defaultFile string
+	autoRotate  bool
+}
+
+// WithRouterMaxOpen sets the max count of files those could be opened at
+// the same time, the least recently used one would be closed if exceeded.
+func WithRouterMaxOpen(n int) FileRouterOption {
+	return func(ro *fileRouterOptions) error {
+		if n <= 0 {
+			return errors.Errorf("WithRouterMaxOpen: n must be positive, got %d", n)
+		}
+		ro.maxOpen = n
+		return nil
+	}
+}
+
+// WithRouterIdleTimeout sets the duration after which a file without any
+// write would be closed, it's 5 minutes by default.
+func WithRouterIdleTimeout(d time.Duration) FileRouterOption {
+	return func(ro *fileRouterOptions) error {
+		if d <= 0 {
+			return errors.Errorf("WithRouterIdleTimeout: duration must be positive, got %s", d)
+		}
+		ro.idleTimeout = d
+		return nil
+	}
+}
+
+// WithRouterDefaultFile sets the file for entries those do not have the keys
+// of pattern. By default, it's the pattern whose keys are replaced by "default".
+func WithRouterDefaultFile(fp string) FileRouterOption {
+	return func(ro *fileRouterOptions) error {
+		ro.defaultFile = fp
+		return nil
+	}
+}
+
+// WithRouterRotate sets whether the routed files are split by day, it's true
+// by default.
+func WithRouterRotate(autoRotate bool) FileRouterOption {
+	return func(ro *fileRouterOptions) error {
+		ro.autoRotate = autoRotate
+		return nil
+	}
+}
+
+// routeSegment is a literal part or a `{key}` part of pattern.
+type routeSegment struct {
+	literal string
+	key     string
+}
+
+// parseRoutePattern parses pattern like `logs/{tenant}/app.log`.
+func parseRoutePattern(pattern string) ([]routeSegment, error) {
+	var (
+		segments []routeSegment
+		keys     int
+	)
+	for rest := pattern; rest != ""; {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			segments = append(segments, routeSegment{literal: rest})
+			break
+		}
+		if start > 0 {
+			segments = append(segments, routeSegment{literal: rest[:start]})
+		}
+
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return nil, errors.Errorf("unclosed '{' in pattern: %s", pattern)
+		}
+		key := rest[start+1 : start+end]
+		if key == "" || strings.ContainsAny(key, "{/\\") {
+			return nil, errors.Errorf("invalid key %q in pattern: %s", key, pattern)
+		}
+		segments = append(segments, routeSegment{key: key})
+		keys++
+		rest = rest[start+end+1:]
+	}
+
+	if keys == 0 {
+		return nil, errors.Errorf("no {key} in pattern: %s", pattern)
+	}
+
+	return segments, nil
+}
+
+// FileRouter is a writer which routes entries into different files by the
+// field values, such as one file per tenant. Files are opened lazily and
+// split by day, the least recently used ones would be closed if too many
+// files are opened, and idle ones would be closed too.
+//
+// It should be set by WithCustomWriter, entries those are written through
+// Write directly (without fields) go to the default file. Writes are
+// serialized by FileRouter.
+type FileRouter struct {
+	opt      *fileRouterOptions
+	segments []routeSegment
+
+	mu     sync.Mutex
+	closed bool
+	files  map[string]*routedFile // opened files by path
+	lru    *list.List             // of *routedFile, the front is the most recently used
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// routedFile is an opened file of FileRouter.
+type routedFile struct {
+	path     string
+	w        *fileHandle
+	elem     *list.Element
+	lastUsed time.Time
+}
+
+var _ fieldsWriter = &FileRouter{}
+
+// NewFileRouter creates a FileRouter, `{key}` in pattern would be replaced by
+// the value of field key, such as `logs/{tenant}/app.log`. Characters those
+// could escape the directory, like path separators, are replaced by '_'.
+func NewFileRouter(pattern string, opts ...FileRouterOption) (*FileRouter, error) {
+	abs, err := filepath.Abs(pattern)
+	if err != nil {
+		return nil, errors.Wrapf(err, "NewFileRouter.Abs pattern: %s", pattern)
+	}
+	segments, err := parseRoutePattern(abs)
+	if err != nil {
+		return nil, errors.Wrap(err, "NewFileRouter")
+	}
+
+	ro := &fileRouterOptions{
+		maxOpen:     _defaultRouterMaxOpen,
+		idleTimeout: _defaultRouterIdleTimeout,
+		autoRotate:  true,
+	}
+	for _, opt := range opts {
+		if err = opt(ro); err != nil {
+			return nil, errors.Wrap(err, "failed to apply option")
+		}
+	}
+	if ro.defaultFile == "" {
+		ro.defaultFile = formatRoute(segments, func(string) string { return _routerDefaultValue })
+	} else if ro.defaultFile, err = filepath.Abs(ro.defaultFile); err != nil {
+		return nil, errors.Wrapf(err, "NewFileRouter.Abs default file: %s", ro.defaultFile)
+	}
+
+	r := &FileRouter{
+		opt:      ro,
+		segments: segments,
+		files:    make(map[string]*routedFile, 8),
+		lru:      list.New(),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if ro.idleTimeout > 0 {
+		go r.closeIdleLoop()
+	} else {
+		close(r.done)
+	}
+
+	return r, nil
+}
+
+func formatRoute(segments []routeSegment, value func(key string) string) string {
+	var sb strings.Builder
+	for _, seg := range segments {
+		if seg.key == "" {
+			sb.WriteString(seg.literal)
+			continue
+		}
+		sb.WriteString(value(seg.key))
+	}
+
+	return filepath.Clean(sb.String())
+}
+
+// route returns the file path of fields, or the default file if any
+// key is missing.
+func (r *FileRouter) route(fields Fields) string {
+	missing := false
+	fp := formatRoute(r.segments, func(key string) string {
+		v, ok := fields[key]
+		if !ok || v == nil {
+			missing = true
+			return ""
+		}
+		s := sanitizeRouteValue(fmt.Sprintf(_interfaceFormat, v))
+		if s == "" {
+			missing = true
+		}
+		return s
+	})
+	if missing {
+		return r.opt.defaultFile
+	}
+
+	return fp
+}
+
+// sanitizeRouteValue replaces the characters those could escape the
+// directory with '_'.
+func sanitizeRouteValue(v string) string {
+	if v == "." || v == ".." {
+		return "_"
+	}
+
+	return strings.Map(func(c rune) rune {
+		if c == '/' || c == '\\' || c == 0 {
+			return '_'
+		}
+		return c
+	}, v)
+}
+
+// Write writes p into the default file.
+func (r *FileRouter) Write(p []byte) (int, error) {
+	return r.writeTo(r.opt.defaultFile, p)
+}
+
+func (r *FileRouter) writeFields(fields Fields, p []byte) (int, error) {
+	return r.writeTo(r.route(fields), p)
+}
+
+func (r *FileRouter) writeTo(fp string, p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return 0, errWriterClosed
+	}
+
+	f, err := r.get(fp)
+	if err != nil {
+		return 0, err
+	}
+
+	return f.w.Write(p)
+}
+
+// get returns the opened file of fp, or opens it. The least recently used
+// file would be closed if there are too many files.
+func (r *FileRouter) get(fp string) (*routedFile, error) {
+	if f, ok := r.files[fp]; ok {
+		f.lastUsed = time.Now()
+		r.lru.MoveToFront(f.elem)
+		return f, nil
+	}
+
+	w, err := _files.acquire(fp, r.opt.autoRotate, defaultFileOptions())
+	if err != nil {
+		return nil, errors.Wrapf(err, "FileRouter.open %s", fp)
+	}
+	f := &routedFile{path: fp, w: w, lastUsed: time.Now()}
+	f.elem = r.lru.PushFront(f)
+	r.files[fp] = f
+
+	for r.lru.Len() > r.opt.maxOpen {
+		r.closeFile(r.lru.Back().Value.(*routedFile))
+	}
+
+	return f, nil
+}
+
+func (r *FileRouter) closeFile(f *routedFile) {
+	r.lru.Remove(f.elem)
+	delete(r.files, f.path)
+	_ = f.w.Close()
+}
+
+// closeIdleLoop closes the files those have been idle for idleTimeout.
+func (r *FileRouter) closeIdleLoop() {
+	defer close(r.done)
+
+	tick := r.opt.idleTimeout / 2
+	if tick < _minRouterIdleTick {
+		tick = _minRouterIdleTick
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case now := <-ticker.C:
+			r.closeIdle(now)
+		}
+	}
+}
+
+func (r *FileRouter) closeIdle(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// the back is the least recently used one.
+	for e := r.lru.Back(); e != nil; e = r.lru.Back() {
+		f := e.Value.(*routedFile)
+		if now.Sub(f.lastUsed) < r.opt.idleTimeout {
+			break
+		}
+		r.closeFile(f)
+	}
+}
+
+// Opened returns the paths of opened files, the most recently used first.
+func (r *FileRouter) Opened() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	paths := make([]string, 0, r.lru.Len())
+	for e := r.lru.Front(); e != nil; e = e.Next() {
+		paths = append(paths, e.Value.(*routedFile).path)
+	}
+
+	return paths
+}
+
+// Sync commits all opened files to stable storage.
+func (r *FileRouter) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errs []error
+	for _, f := range r.files {
+		if err := f.w.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return combineErrors("FileRouter.Sync", errs)
+}
+
+// Close closes all opened files, it's safe to be called more than once.
+func (r *FileRouter) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	r.mu.Unlock()
+
+	close(r.stop)
+	<-r.done
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errs []error
+	for _, f := range r.files {
+		if err := f.w.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	r.files = nil
+	r.lru.Init()
+
+	return combineErrors("FileRouter.Close", errs)
+}
@@ -0,0 +1,161 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRouterDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "log-router")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	return dir
+}
+
+func readFile(t *testing.T, fp string) string {
+	data, err := ioutil.ReadFile(fp)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func Test_FileRouter(t *testing.T) {
+	dir := newTestRouterDir(t)
+	r, err := NewFileRouter(filepath.Join(dir, "{tenant}", "app.log"), WithRouterRotate(false))
+	require.NoError(t, err)
+
+	l, err := NewLogger(WithCustomWriter(r))
+	require.NoError(t, err)
+
+	l.WithField("tenant", "a").Info("to a")
+	l.WithField("tenant", "b").Info("to b")
+	l.WithField("tenant", "../etc").Info("escaped")
+	l.Info("to default")
+	require.NoError(t, l.Close())
+
+	assert.Contains(t, readFile(t, filepath.Join(dir, "a", "app.log")), "to a")
+	assert.Contains(t, readFile(t, filepath.Join(dir, "b", "app.log")), "to b")
+	assert.Contains(t, readFile(t, filepath.Join(dir, ".._etc", "app.log")), "escaped")
+	assert.Contains(t, readFile(t, filepath.Join(dir, "default", "app.log")), "to default")
+	assert.NotContains(t, readFile(t, filepath.Join(dir, "a", "app.log")), "to b")
+}
+
+func Test_FileRouter_withStdout(t *testing.T) {
+	dir := newTestRouterDir(t)
+	r, err := NewFileRouter(filepath.Join(dir, "{tenant}", "app.log"), WithRouterRotate(false))
+	require.NoError(t, err)
+
+	l, err := NewLogger(WithCustomWriter(r), WithStdout(true))
+	require.NoError(t, err)
+
+	l.WithField("tenant", "a").Info("to a")
+	l.WithField("tenant", "b").Info("to b")
+	l.Info("to default")
+	require.NoError(t, l.Close())
+
+	assert.Contains(t, readFile(t, filepath.Join(dir, "a", "app.log")), "to a")
+	assert.Contains(t, readFile(t, filepath.Join(dir, "b", "app.log")), "to b")
+	def := readFile(t, filepath.Join(dir, "default", "app.log"))
+	assert.Contains(t, def, "to default")
+	assert.NotContains(t, def, "to a")
+	assert.NotContains(t, def, "to b")
+}
+
+func Test_FileRouter_defaultFile(t *testing.T) {
+	dir := newTestRouterDir(t)
+	r, err := NewFileRouter(
+		filepath.Join(dir, "{tenant}-{region}.log"),
+		WithRouterDefaultFile(filepath.Join(dir, "unknown.log")),
+	)
+	require.NoError(t, err)
+	defer r.Close()
+
+	l, err := NewLogger(WithCustomWriter(r))
+	require.NoError(t, err)
+
+	l.WithFields(Fields{"tenant": "a", "region": "eu"}).Info("a in eu")
+	l.WithField("tenant", "a").Info("region missing")
+	require.NoError(t, r.Sync())
+
+	assert.Contains(t, readFile(t, filepath.Join(dir, "a-eu.log")), "a in eu")
+	assert.Contains(t, readFile(t, filepath.Join(dir, "unknown.log")), "region missing")
+}
+
+func Test_FileRouter_maxOpen(t *testing.T) {
+	dir := newTestRouterDir(t)
+	r, err := NewFileRouter(filepath.Join(dir, "{tenant}.log"), WithRouterMaxOpen(2), WithRouterRotate(false))
+	require.NoError(t, err)
+	defer r.Close()
+
+	l, err := NewLogger(WithCustomWriter(r))
+	require.NoError(t, err)
+
+	l.WithField("tenant", "a").Info("1")
+	l.WithField("tenant", "b").Info("2")
+	l.WithField("tenant", "a").Info("3")
+	l.WithField("tenant", "c").Info("4")
+	assert.Equal(t, []string{filepath.Join(dir, "c.log"), filepath.Join(dir, "a.log")}, r.Opened())
+
+	// the closed file would be reopened and appended.
+	l.WithField("tenant", "b").Info("5")
+	content := readFile(t, filepath.Join(dir, "b.log"))
+	assert.Contains(t, content, "2")
+	assert.Contains(t, content, "5")
+}
+
+func Test_FileRouter_idleTimeout(t *testing.T) {
+	dir := newTestRouterDir(t)
+	r, err := NewFileRouter(filepath.Join(dir, "{tenant}.log"), WithRouterIdleTimeout(20*time.Millisecond))
+	require.NoError(t, err)
+	defer r.Close()
+
+	_, err = r.writeFields(Fields{"tenant": "a"}, []byte("idle\n"))
+	require.NoError(t, err)
+	assert.Len(t, r.Opened(), 1)
+	assert.Eventually(t, func() bool { return len(r.Opened()) == 0 }, time.Second, 5*time.Millisecond)
+}
+
+func Test_FileRouter_idleTimeoutTiny(t *testing.T) {
+	dir := newTestRouterDir(t)
+	_, err := NewFileRouter(filepath.Join(dir, "{tenant}.log"), WithRouterIdleTimeout(0))
+	assert.Error(t, err)
+	_, err = NewFileRouter(filepath.Join(dir, "{tenant}.log"), WithRouterIdleTimeout(-time.Second))
+	assert.Error(t, err)
+
+	// the tick is clamped, so it would not panic.
+	r, err := NewFileRouter(filepath.Join(dir, "{tenant}.log"), WithRouterIdleTimeout(time.Nanosecond))
+	require.NoError(t, err)
+	_, err = r.writeFields(Fields{"tenant": "a"}, []byte("idle\n"))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return len(r.Opened()) == 0 }, time.Second, 5*time.Millisecond)
+	require.NoError(t, r.Close())
+}
+
+func Test_FileRouter_closed(t *testing.T) {
+	r, err := NewFileRouter(filepath.Join(newTestRouterDir(t), "{tenant}.log"))
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.NoError(t, r.Close())
+
+	_, err = r.Write([]byte("x"))
+	assert.Equal(t, errWriterClosed, err)
+}
+
+func Test_parseRoutePattern(t *testing.T) {
+	segments, err := parseRoutePattern("logs/{tenant}/{app}.log")
+	require.NoError(t, err)
+	assert.Equal(t, []routeSegment{
+		{literal: "logs/"}, {key: "tenant"}, {literal: "/"}, {key: "app"}, {literal: ".log"},
+	}, segments)
+
+	for _, pattern := range []string{"app.log", "logs/{tenant", "logs/{}/app.log", "logs/{a/b}.log"} {
+		_, err = parseRoutePattern(pattern)
+		assert.Error(t, err, pattern)
+	}
+}
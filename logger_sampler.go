@@ -0,0 +1,212 @@
+package log
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// _samplerBuckets is the count of counters per level, keys are hashed
+	// into buckets, so different keys may share one counter rarely.
+	_samplerBuckets = 4096
+	_samplerLevels  = LevelDebug + 1
+)
+
+// SamplerOption to apply single function into `so`.
+type SamplerOption func(so *samplerOptions) error
+
+type samplerOptions struct {
+	byCaller bool
+	hook     func(e *Entry, dropped bool)
+}
+
+// WithSampleByCaller keys entries by caller instead of message, it only takes
+// effect if Logger reports caller, otherwise message is used.
+func WithSampleByCaller() SamplerOption {
+	return func(so *samplerOptions) error {
+		so.byCaller = true
+		return nil
+	}
+}
+
+// WithSamplerHook sets fn which would be called with every sampling decision,
+// it's called synchronously so it should be fast.
+func WithSamplerHook(fn func(e *Entry, dropped bool)) SamplerOption {
+	return func(so *samplerOptions) error {
+		so.hook = fn
+		return nil
+	}
+}
+
+// samplerCounter counts entries of one key in current tick.
+type samplerCounter struct {
+	resetAt int64 // unix nano when the counter should be reset
+	count   uint64
+}
+
+// incr increases the counter, and resets it if the tick has passed.
+func (c *samplerCounter) incr(now int64, tick time.Duration) uint64 {
+	resetAt := atomic.LoadInt64(&c.resetAt)
+	if resetAt > now {
+		return atomic.AddUint64(&c.count, 1)
+	}
+
+	atomic.StoreUint64(&c.count, 1)
+	if !atomic.CompareAndSwapInt64(&c.resetAt, resetAt, now+int64(tick)) {
+		// another goroutine has reset the counter.
+		return atomic.AddUint64(&c.count, 1)
+	}
+
+	return 1
+}
+
+// SamplerStats is the counters of sampling decisions.
+type SamplerStats struct {
+	Sampled uint64 // count of entries let through.
+	Dropped uint64 // count of entries dropped.
+}
+
+// Sampler is a Processor which limits the same entries in hot paths: in
+// every tick, the first N entries of a key (level and message, or caller)
+// are let through, and then only every Mth. Fatal entries are never dropped.
+// Counters are a lock-free hashed array, so it's cheap to be called.
+type Sampler struct {
+	opt        *samplerOptions
+	tick       time.Duration
+	first      uint64
+	thereafter uint64
+
+	counters [_samplerLevels][_samplerBuckets]samplerCounter
+
+	sampled uint64
+	dropped uint64
+}
+
+var _ Processor = &Sampler{}
+
+// NewSampler creates a Sampler lets the first entries and every thereafter-th
+// entries through per tick, thereafter 0 means dropping all after the first.
+func NewSampler(tick time.Duration, first, thereafter int, opts ...SamplerOption) (*Sampler, error) {
+	if tick <= 0 {
+		return nil, errors.Errorf("NewSampler: tick must be positive, got %s", tick)
+	}
+	if first < 0 || thereafter < 0 {
+		return nil, errors.Errorf("NewSampler: negative first %d or thereafter %d", first, thereafter)
+	}
+
+	so := &samplerOptions{}
+	for _, opt := range opts {
+		if err := opt(so); err != nil {
+			return nil, errors.Wrap(err, "failed to apply option")
+		}
+	}
+
+	return &Sampler{
+		opt:        so,
+		tick:       tick,
+		first:      uint64(first),
+		thereafter: uint64(thereafter),
+	}, nil
+}
+
+// Process makes the sampling decision of e.
+func (s *Sampler) Process(e *Entry) bool {
+	if e.Level == LevelFatal || e.Level >= _samplerLevels {
+		return true
+	}
+
+	counter := &s.counters[e.Level][s.bucket(e)]
+	n := counter.incr(e.Time.UnixNano(), s.tick)
+	pass := n <= s.first || (s.thereafter > 0 && (n-s.first)%s.thereafter == 0)
+
+	if pass {
+		atomic.AddUint64(&s.sampled, 1)
+	} else {
+		atomic.AddUint64(&s.dropped, 1)
+	}
+	if s.opt.hook != nil {
+		s.opt.hook(e, !pass)
+	}
+
+	return pass
+}
+
+// bucket hashes the key of e into a counter index.
+func (s *Sampler) bucket(e *Entry) uint32 {
+	h := uint32(_fnvOffset32)
+	if s.opt.byCaller && e.Caller != nil {
+		h = fnv32a(h, e.Caller.File)
+		h = fnv32a(h, strconv.Itoa(e.Caller.Line))
+	} else {
+		h = fnv32a(h, e.Message)
+	}
+
+	return h % _samplerBuckets
+}
+
+const (
+	_fnvOffset32 = 2166136261
+	_fnvPrime32  = 16777619
+)
+
+// fnv32a is FNV-1a hash of s without allocation.
+func fnv32a(h uint32, s string) uint32 {
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= _fnvPrime32
+	}
+
+	return h
+}
+
+// Stats returns the total counters of sampling decisions.
+func (s *Sampler) Stats() SamplerStats {
+	return SamplerStats{
+		Sampled: atomic.LoadUint64(&s.sampled),
+		Dropped: atomic.LoadUint64(&s.dropped),
+	}
+}
+
+// ReportTo logs a Warning summary line into l every interval if any entry has
+// been dropped in the interval, the summary bypasses the level and processors
+// of l, so it would be neither filtered nor sampled. The returned stop
+// function stops reporting. Nothing is reported if interval is not positive.
+func (s *Sampler) ReportTo(l *Logger, interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	last := s.Stats()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				cur := s.Stats()
+				if dropped := cur.Dropped - last.Dropped; dropped != 0 {
+					l.logDirect(LevelWarning, Fields{
+						"sampled": cur.Sampled - last.Sampled,
+						"dropped": dropped,
+					}, fmt.Sprintf("sampler dropped %d entries in %s", dropped, interval))
+				}
+				last = cur
+			}
+		}
+	}()
+
+	var closed int32
+	return func() {
+		if atomic.CompareAndSwapInt32(&closed, 0, 1) {
+			close(done)
+		}
+	}
+}
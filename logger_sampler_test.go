@@ -0,0 +1,147 @@
+package log
+
+import (
+	"bytes"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Sampler(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		drops    int
+		decision int
+	)
+	sampler, err := NewSampler(time.Hour, 3, 5, WithSamplerHook(func(e *Entry, dropped bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		decision++
+		if dropped {
+			drops++
+		}
+	}))
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	l, err := NewLogger(WithCustomWriter(buf), WithProcessors(sampler))
+	require.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		l.Warn("hot path")
+	}
+	// other messages and levels are counted separately.
+	l.Info("hot path")
+	l.Warn("cold path")
+
+	// 1, 2, 3, 8, 13, 18 are let through, and the cold one.
+	assert.Equal(t, 7, strings.Count(buf.String(), "[WRN] "))
+	assert.Equal(t, 1, strings.Count(buf.String(), "[INF] "))
+	assert.Equal(t, SamplerStats{Sampled: 8, Dropped: 14}, sampler.Stats())
+	assert.Equal(t, 22, decision)
+	assert.Equal(t, 14, drops)
+}
+
+func Test_Sampler_tick(t *testing.T) {
+	sampler, err := NewSampler(time.Second, 1, 0)
+	require.NoError(t, err)
+
+	now := time.Now()
+	e := &Entry{Level: LevelInfo, Message: "m", Time: now}
+	assert.True(t, sampler.Process(e))
+	assert.False(t, sampler.Process(e))
+
+	// counters are reset in next tick.
+	e.Time = now.Add(time.Second)
+	assert.True(t, sampler.Process(e))
+	assert.False(t, sampler.Process(e))
+
+	// fatal entries are never dropped.
+	e.Level = LevelFatal
+	assert.True(t, sampler.Process(e))
+	assert.True(t, sampler.Process(e))
+}
+
+func Test_Sampler_concurrent(t *testing.T) {
+	sampler, err := NewSampler(time.Hour, 10, 0)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				sampler.Process(&Entry{Level: LevelInfo, Message: "m", Time: time.Now()})
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, SamplerStats{Sampled: 10, Dropped: 790}, sampler.Stats())
+}
+
+func Test_Sampler_ReportTo(t *testing.T) {
+	sampler, err := NewSampler(time.Hour, 1, 0)
+	require.NoError(t, err)
+
+	var (
+		mu       sync.Mutex
+		messages []string
+	)
+	l, err := NewLogger(
+		WithCustomWriter(&bytes.Buffer{}),
+		WithSinks(SinkFunc(func(e *Entry) error {
+			mu.Lock()
+			defer mu.Unlock()
+			messages = append(messages, e.Message)
+			return nil
+		})),
+		// the summary is neither filtered by level nor processed.
+		WithLevel(LevelError),
+		WithProcessors(sampler, DropIf(func(e *Entry) bool { return strings.HasPrefix(e.Message, "sampler") })),
+	)
+	require.NoError(t, err)
+
+	stop := sampler.ReportTo(l, 10*time.Millisecond)
+	defer stop()
+	sampler.Process(&Entry{Level: LevelInfo, Message: "m", Time: time.Now()})
+	sampler.Process(&Entry{Level: LevelInfo, Message: "m", Time: time.Now()})
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(messages) == 1 && strings.HasPrefix(messages[0], "sampler dropped 1 entries")
+	}, time.Second, 5*time.Millisecond)
+	stop()
+}
+
+func Test_Sampler_ReportTo_invalidInterval(t *testing.T) {
+	sampler, err := NewSampler(time.Hour, 1, 0)
+	require.NoError(t, err)
+	l, err := NewLogger(WithCustomWriter(&bytes.Buffer{}))
+	require.NoError(t, err)
+
+	assert.NotPanics(t, func() {
+		sampler.ReportTo(l, 0)()
+		sampler.ReportTo(l, -time.Second)()
+	})
+}
+
+func Test_fnv32a(t *testing.T) {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte("hello world"))
+	assert.Equal(t, h.Sum32(), fnv32a(_fnvOffset32, "hello world"))
+}
+
+func Test_NewSampler_invalid(t *testing.T) {
+	_, err := NewSampler(0, 1, 1)
+	assert.Error(t, err)
+	_, err = NewSampler(time.Second, -1, 1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,156 @@
+package log
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const _defaultScopeMaxEntries = 1000
+
+// scopeKey is the context key of logScope.
+type scopeKey struct{}
+
+// logScope holds the entries logged with the context of BeginScope.
+type logScope struct {
+	logger *Logger
+	ctx    context.Context
+	start  time.Time
+
+	holdBuffer
+	ended bool   // guarded by holdBuffer.mu
+	total uint64 // count of held entries including discarded ones, guarded by holdBuffer.mu
+}
+
+// BeginScope begins a scope, such as an HTTP request or an RPC call. Entries
+// logged through the returned context by WithContext are held in memory
+// instead of being written. The returned end function should be called when
+// the scope finishes: if failed is true or the scope is slower than the
+// threshold of WithScopeSlowThreshold, the held entries are written followed
+// by a Warning summary, otherwise they are discarded and only an Info summary
+// is written.
+//
+// Like WithFingersCrossed, sinks, hooks and FlightRecorder are not affected.
+// Fatal entries are never held, the held entries are written before them.
+// Entries logged after end are written directly. If scopes are nested, the
+// innermost one holds the entries.
+func (l *Logger) BeginScope(ctx context.Context) (context.Context, func(failed bool)) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	size := l.opt.scopeMaxEntries
+	if size <= 0 {
+		size = _defaultScopeMaxEntries
+	}
+	sc := &logScope{
+		logger:     l,
+		start:      time.Now(),
+		holdBuffer: newHoldBuffer(size),
+	}
+	sc.ctx = context.WithValue(ctx, scopeKey{}, sc)
+
+	return sc.ctx, sc.end
+}
+
+// scope returns the scope of e's context, or nil if it's not began by e's Logger.
+func (e *entry) scope() *logScope {
+	if e.ctx == nil {
+		return nil
+	}
+
+	sc, ok := e.ctx.Value(scopeKey{}).(*logScope)
+	if !ok || sc.logger != e.logger {
+		return nil
+	}
+
+	return sc
+}
+
+// hold holds data of e, it returns false if data should be written directly
+// since the scope has ended or it's a Fatal entry.
+func (sc *logScope) hold(e *entry, lv Level, data []byte) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.ended {
+		return false
+	}
+	if lv == LevelFatal {
+		sc.releaseLocked(e.writeOut)
+		return false
+	}
+
+	sc.total++
+	if sc.holdLocked(lv, e.heldFields(), data) {
+		e.logger.metrics.incrDropped()
+	}
+	return true
+}
+
+func (sc *logScope) end(failed bool) {
+	sc.mu.Lock()
+	if sc.ended {
+		sc.mu.Unlock()
+		return
+	}
+	sc.ended = true
+
+	elapsed := time.Since(sc.start)
+	slow := sc.logger.opt.scopeSlow > 0 && elapsed >= sc.logger.opt.scopeSlow
+	if failed || slow {
+		e := sc.logger.newEntry()
+		sc.releaseLocked(func(lv Level, fields Fields, data []byte) {
+			if sc.logger.isClosed() {
+				sc.logger.writeClosed(data)
+				return
+			}
+			e.writeOut(lv, fields, data)
+		})
+		sc.logger.releaseEntry(e)
+	}
+	fields := Fields{
+		"scope_entries": sc.total,
+		"scope_elapsed": elapsed.String(),
+	}
+	if sc.discarded != 0 {
+		fields["scope_discarded"] = sc.discarded
+	}
+	sc.mu.Unlock()
+
+	e := sc.logger.WithContext(sc.ctx).WithFields(fields)
+	switch {
+	case failed:
+		e.Warn("scope failed")
+	case slow:
+		e.Warn("scope slow")
+	default:
+		e.Info("scope finished")
+	}
+}
+
+// WithScopeSlowThreshold sets the duration after which the scope of
+// BeginScope is slow, its entries would be written even if it succeeded.
+// 0 means never.
+func WithScopeSlowThreshold(d time.Duration) LoggerOption {
+	return func(lo *options) error {
+		if d < 0 {
+			return errors.Errorf("WithScopeSlowThreshold: negative duration %s", d)
+		}
+		lo.scopeSlow = d
+		return nil
+	}
+}
+
+// WithScopeMaxEntries sets the max count of entries held by one scope of
+// BeginScope, the oldest one would be discarded if exceeded. It's 1000 by default.
+func WithScopeMaxEntries(n int) LoggerOption {
+	return func(lo *options) error {
+		if n <= 0 {
+			return errors.Errorf("WithScopeMaxEntries: n must be positive, got %d", n)
+		}
+		lo.scopeMaxEntries = n
+		return nil
+	}
+}
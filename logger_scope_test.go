@@ -0,0 +1,107 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lines returns the non-empty lines of s.
+func lines(s string) []string {
+	var ls []string
+	for _, line := range strings.Split(s, "\n") {
+		if line != "" {
+			ls = append(ls, line)
+		}
+	}
+
+	return ls
+}
+
+func Test_Logger_BeginScope(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l, err := NewLogger(WithCustomWriter(buf))
+	require.NoError(t, err)
+
+	ctx, end := l.BeginScope(context.Background())
+	l.WithContext(ctx).Debug("held debug")
+	l.WithContext(ctx).WithFields(Fields{"k": "v"}).Info("held info")
+	l.Info("not scoped")
+	assert.Equal(t, 1, len(lines(buf.String())))
+
+	end(false)
+	ls := lines(buf.String())
+	require.Len(t, ls, 2)
+	assert.Contains(t, ls[1], "scope finished")
+	assert.Contains(t, ls[1], "scope_entries=\"2\"")
+	assert.NotContains(t, buf.String(), "held")
+
+	end(true)
+	assert.Len(t, lines(buf.String()), 2, "end is called only once")
+
+	l.WithContext(ctx).Info("after end")
+	assert.Contains(t, buf.String(), "after end")
+}
+
+func Test_Logger_BeginScope_failed(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l, err := NewLogger(WithCustomWriter(buf), WithScopeMaxEntries(2))
+	require.NoError(t, err)
+
+	ctx, end := l.BeginScope(context.Background())
+	l.WithContext(ctx).Debug("scoped 1")
+	l.WithContext(ctx).Debug("scoped 2")
+	l.WithContext(ctx).Error("scoped 3")
+	assert.Empty(t, buf.String())
+
+	end(true)
+	ls := lines(buf.String())
+	require.Len(t, ls, 3)
+	assert.Contains(t, ls[0], "scoped 2")
+	assert.Contains(t, ls[1], "scoped 3")
+	assert.Contains(t, ls[2], "scope failed")
+	assert.Contains(t, ls[2], "scope_entries=\"3\"")
+	assert.Contains(t, ls[2], "scope_discarded=\"1\"")
+}
+
+func Test_Logger_BeginScope_slow(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l, err := NewLogger(WithCustomWriter(buf), WithScopeSlowThreshold(10*time.Millisecond))
+	require.NoError(t, err)
+
+	ctx, end := l.BeginScope(context.Background())
+	l.WithContext(ctx).Info("scoped")
+	time.Sleep(20 * time.Millisecond)
+	end(false)
+
+	ls := lines(buf.String())
+	require.Len(t, ls, 2)
+	assert.Contains(t, ls[0], "scoped")
+	assert.Contains(t, ls[1], "scope slow")
+}
+
+func Test_Logger_BeginScope_otherLogger(t *testing.T) {
+	buf1, buf2 := &bytes.Buffer{}, &bytes.Buffer{}
+	l1, err := NewLogger(WithCustomWriter(buf1))
+	require.NoError(t, err)
+	l2, err := NewLogger(WithCustomWriter(buf2))
+	require.NoError(t, err)
+
+	ctx, end := l1.BeginScope(context.Background())
+	defer end(false)
+	l2.WithContext(ctx).Info("written by l2")
+	assert.Contains(t, buf2.String(), "written by l2")
+	assert.Empty(t, buf1.String())
+}
+
+func Test_WithScope_invalid(t *testing.T) {
+	_, err := NewLogger(WithScopeSlowThreshold(-time.Second))
+	assert.Error(t, err)
+	_, err = NewLogger(WithScopeMaxEntries(0))
+	assert.Error(t, err)
+}
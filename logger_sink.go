@@ -0,0 +1,77 @@
+package log
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// Entry is the structured snapshot of a log record. It's built by output
+// and handed to sinks which need more than the formatted bytes.
+type Entry struct {
+	Level   Level
+	Message string
+	Time    time.Time
+
+	// Fields contains global fields, entry fields and the parsed context field.
+	// It's a copy of entry's fields, so it's safe to be retained, but it's
+	// shared by all sinks, so it should not be modified.
+	Fields Fields
+
+	// Caller is set only if the logger reports caller.
+	Caller *runtime.Frame
+
+	// Context is the ctx which was attached by WithContext, maybe nil.
+	Context context.Context
+}
+
+// Sink receives every Entry which passed the level check, it works beside
+// the writer rather than replacing it, so structured backends could be
+// fed without parsing the text output.
+type Sink interface {
+	// Emit would be called synchronously in the logging goroutine,
+	// so it should not block for long.
+	Emit(e *Entry) error
+}
+
+// SinkFunc is an adapter to allow the use of ordinary functions as Sink.
+type SinkFunc func(e *Entry) error
+
+// Emit calls f(e).
+func (f SinkFunc) Emit(e *Entry) error {
+	return f(e)
+}
+
+// newEntrySnapshot builds an Entry from e, fields would be copied.
+func newEntrySnapshot(e *entry, msg string, now time.Time, frm *runtime.Frame) *Entry {
+	fields := make(Fields, len(e.fields))
+	copyFields(fields, e.fields)
+
+	return &Entry{
+		Level:   e.lv,
+		Message: msg,
+		Time:    now,
+		Fields:  fields,
+		Caller:  frm,
+		Context: e.ctx,
+	}
+}
+
+// formatEntry formats Entry by f, so that a structured Entry could be output
+// in the same way as the writer does.
+func formatEntry(f Formatter, en *Entry) ([]byte, error) {
+	e := entry{
+		lv:         en.Level,
+		withCaller: en.Caller != nil,
+		fixedField: &fixedField{Timestamp: en.Time.Unix()},
+		fields:     en.Fields,
+		ctx:        en.Context,
+	}
+	if en.Caller != nil {
+		e.fixedField.File = en.Caller.File + ":" + strconv.Itoa(en.Caller.Line)
+		e.fixedField.Fn = en.Caller.Function
+	}
+
+	return f.Format(&e, en.Message)
+}
@@ -0,0 +1,140 @@
+package log
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// syncer is implemented by writers and sinks which buffer data,
+// such as *os.File and the batched sinks.
+type syncer interface {
+	Sync() error
+}
+
+// Sync flushes every processor, writer, sink and hook which implements `Sync() error`,
+// os.Stdout and os.Stderr are skipped. It's safe to be called at any time,
+// and it does nothing after the Logger has been closed.
+func (l *Logger) Sync() error {
+	if l.isClosed() {
+		return nil
+	}
+
+	var errs []error
+	for _, v := range l.syncTargets() {
+		if s, ok := v.(syncer); ok {
+			if err := s.Sync(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return combineErrors("Sync", errs)
+}
+
+// Close syncs and closes every processor, writer, sink and hook which
+// implements io.Closer, os.Stdout and os.Stderr are never closed. Processors
+// are synced at first, so that their summaries are written before writers
+// are closed. It's safe to be called more than once, only the first call
+// takes effect.
+//
+// After Close, the Logger would not crash: formatted entries are written into
+// the fallback writer (see WithFallbackWriter) or os.Stderr if it's not set,
+// and sinks are skipped.
+func (l *Logger) Close() error {
+	if l.isClosed() {
+		return nil
+	}
+	for _, p := range l.opt.processors {
+		if s, ok := p.(syncer); ok {
+			_ = s.Sync()
+		}
+	}
+	if !atomic.CompareAndSwapInt32(&l.closed, 0, 1) {
+		return nil
+	}
+
+	var errs []error
+	for _, v := range l.syncTargets() {
+		if s, ok := v.(syncer); ok {
+			if err := s.Sync(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if c, ok := v.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return combineErrors("Close", errs)
+}
+
+// commit applies the fsync policies after an entry of lv has been written.
+func (l *Logger) commit(lv Level) {
+	for _, c := range l.opt.committers {
+		if err := c.commit(lv); err != nil {
+			l.handleError(ErrorKindSync, err)
+		}
+	}
+}
+
+func (l *Logger) isClosed() bool {
+	return atomic.LoadInt32(&l.closed) == 1
+}
+
+// writeClosed writes data after the Logger has been closed.
+func (l *Logger) writeClosed(data []byte) {
+	w := l.opt.fallback
+	if w == nil {
+		w = os.Stderr
+	}
+
+	if _, err := w.Write(data); err != nil {
+		l.handleError(ErrorKindFallback, err)
+	}
+}
+
+// syncTargets returns the processors, writers, sinks and hooks those are
+// owned by Logger, processors come first since they may log summaries.
+func (l *Logger) syncTargets() []interface{} {
+	hooks := l.hooks.all()
+	targets := make([]interface{}, 0,
+		len(l.opt.processors)+len(l.opt.outputs)+len(l.opt.sinks)+len(hooks))
+	for _, p := range l.opt.processors {
+		targets = append(targets, p)
+	}
+	for _, w := range l.opt.outputs {
+		if isStdStream(w) {
+			continue
+		}
+		targets = append(targets, w)
+	}
+	for _, sink := range l.opt.sinks {
+		targets = append(targets, sink)
+	}
+	for _, hook := range hooks {
+		targets = append(targets, hook)
+	}
+
+	return targets
+}
+
+func isStdStream(w io.Writer) bool {
+	return w == os.Stdout || w == os.Stderr
+}
+
+// combineErrors returns the first error with the count of errors, or nil.
+func combineErrors(op string, errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errors.Wrap(errs[0], op)
+	}
+
+	return errors.Wrapf(errs[0], "%s: %d errors occurred, the first one", op, len(errs))
+}
@@ -0,0 +1,151 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// closableWriter counts Sync and Close calls.
+type closableWriter struct {
+	bytes.Buffer
+	syncs, closes int
+	err           error
+}
+
+func (w *closableWriter) Sync() error {
+	w.syncs++
+	return w.err
+}
+
+func (w *closableWriter) Close() error {
+	w.closes++
+	return w.err
+}
+
+// closableSink counts emitted entries and Sync and Close calls.
+type closableSink struct {
+	closableWriter
+	emitted int
+}
+
+func (s *closableSink) Emit(e *Entry) error {
+	s.emitted++
+	return nil
+}
+
+func Test_Logger_SyncClose(t *testing.T) {
+	w := &closableWriter{}
+	sink := &closableSink{}
+	fallback := &bytes.Buffer{}
+	l, err := NewLogger(WithCustomWriter(w), WithSinks(sink), WithFallbackWriter(fallback))
+	require.NoError(t, err)
+
+	l.Info("before")
+	assert.NoError(t, l.Sync())
+	assert.Equal(t, 1, w.syncs)
+	assert.Equal(t, 1, sink.syncs)
+
+	assert.NoError(t, l.Close())
+	assert.NoError(t, l.Close())
+	assert.NoError(t, l.Sync())
+	assert.Equal(t, 1, w.closes)
+	assert.Equal(t, 1, sink.closes)
+	assert.Equal(t, 2, w.syncs)
+
+	// after closed, entries go to the fallback and sinks are skipped.
+	l.Info("after")
+	assert.Contains(t, w.String(), "before")
+	assert.NotContains(t, w.String(), "after")
+	assert.Contains(t, fallback.String(), "after")
+	assert.Equal(t, 1, sink.emitted)
+}
+
+func Test_Logger_SyncClose_errors(t *testing.T) {
+	w1 := &closableWriter{err: errors.New("w1 failed")}
+	w2 := &closableWriter{err: errors.New("w2 failed")}
+	l, err := NewLogger(WithCustomWriter(w1), WithSinks(&closableSink{closableWriter: *w2}))
+	require.NoError(t, err)
+
+	err = l.Sync()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2 errors occurred")
+	assert.Contains(t, err.Error(), "w1 failed")
+}
+
+func Test_Logger_Close_skipStdStreams(t *testing.T) {
+	l, err := NewLogger(WithStdout(true))
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+
+	// os.Stdout is still usable.
+	_, err = os.Stdout.Write(nil)
+	assert.NoError(t, err)
+}
+
+func Test_Logger_Close_file(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-close")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fallback := &bytes.Buffer{}
+	fp := filepath.Join(dir, "app.log")
+	l, err := NewLogger(WithFileLog(fp, true), WithFallbackWriter(fallback))
+	require.NoError(t, err)
+
+	l.Info("into file")
+	require.NoError(t, l.Sync())
+	data, err := ioutil.ReadFile(fp)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "into file")
+
+	require.NoError(t, l.Close())
+	l.Info("into fallback")
+	assert.Contains(t, fallback.String(), "into fallback")
+	assert.Equal(t, uint64(0), l.ErrorStats().WriteErrors)
+}
+
+func Test_fileWriter_closed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-close")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w, err := newFileWriter(filepath.Join(dir, "app.log"), false, defaultFileOptions())
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, w.Close())
+	assert.NoError(t, w.Sync())
+
+	_, err = w.Write([]byte("x"))
+	assert.Equal(t, errWriterClosed, err)
+}
+
+func Test_batcher_sync(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		flushed []interface{}
+	)
+	b := newBatcher(100, time.Hour, func(items []interface{}) {
+		mu.Lock()
+		flushed = append(flushed, items...)
+		mu.Unlock()
+	})
+	defer b.close()
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, b.add(i))
+	}
+	b.sync()
+
+	mu.Lock()
+	assert.Len(t, flushed, 10)
+	mu.Unlock()
+}
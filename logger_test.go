@@ -2,6 +2,8 @@ package log
 
 import (
 	"bytes"
+	"os"
+	"path/filepath"
 	"strconv"
 	"sync"
 	"testing"
@@ -109,17 +111,20 @@ func Test_Logger_SetTimeFormat(t *testing.T) {
 func Test_FileSplit(t *testing.T) {
 	dir := "./testdata"
 	filename := "split.log"
+	fp := filepath.Join(dir, filename)
 
 	// prepare file
-	_, err := open(assembleFilename(dir, filename, true))
+	w, err := newFileWriter(fp, false, defaultFileOptions())
 	assert.Nil(t, err)
+	defer w.Close()
 
-	// rename file
-	err = rename(dir, filename)
+	// rename file and renew file
+	err = w.rotate()
 	assert.Nil(t, err)
 
-	// renew file
-	_, err = open(assembleFilename(dir, filename, true))
+	_, err = os.Stat(filepath.Join(dir, rotateFilenameAt(filename, w.lastSplit)))
+	assert.Nil(t, err)
+	_, err = os.Stat(fp)
 	assert.Nil(t, err)
 }
 
@@ -128,10 +133,11 @@ func Test_Logger_FileSplit(t *testing.T) {
 	filename := strconv.FormatInt(time.Now().Unix(), 10) + ".log"
 
 	l, err := NewLogger(
-		WithFileLog(assembleFilename(dir, filename, true), true),
+		WithFileLog(filepath.Join(dir, filename), true),
 		WithStdout(true),
 	)
 	assert.Nil(t, err)
+	defer l.Close()
 
 	// ticker := time.NewTicker(1 * time.Second)
 	threshold := 3
@@ -140,14 +146,9 @@ func Test_Logger_FileSplit(t *testing.T) {
 	switchWriter := func() {
 		t.Log("switch writer")
 
-		// rename file
-		err = rename(dir, filename)
-		assert.Nil(t, err)
-
-		// renew file
-		fd, err := open(assembleFilename(dir, filename, true))
+		// rename file and renew file
+		err = l.opt.outputs[0].(*fileHandle).rotate()
 		assert.Nil(t, err)
-		l.opt.w = fd
 	}
 
 	for counter := 1; counter < 100; counter++ {
@@ -179,11 +180,11 @@ func Test_Logger_concurrent(t *testing.T) {
 	wg.Wait()
 }
 
-func Test_shouldSplitByTime(t *testing.T) {
+func Test_fileWriter_shouldRotate(t *testing.T) {
 	now := time.Now()
 
 	type args struct {
-		lastSplitTimestamp time.Time
+		lastSplit time.Time
 	}
 	tests := []struct {
 		name string
@@ -193,32 +194,31 @@ func Test_shouldSplitByTime(t *testing.T) {
 		{
 			name: "case 0",
 			args: args{
-				lastSplitTimestamp: now, // now
+				lastSplit: now, // now
 			},
 			want: false,
 		},
 		{
 			name: "case 1",
 			args: args{
-				lastSplitTimestamp: now.Add(-24 * time.Hour), // yesterday
+				lastSplit: now.Add(-24 * time.Hour), // yesterday
 			},
 			want: true,
 		},
 		{
 			name: "case 2",
 			args: args{
-				lastSplitTimestamp: now.Add(-48 * time.Hour),
+				lastSplit: now.Add(-48 * time.Hour),
 			}, // the day before yesterday
 			want: true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// set var
-			lastSplitTimestamp = tt.args.lastSplitTimestamp
+			w := &fileWriter{lastSplit: tt.args.lastSplit}
 
-			if got := shouldSplitByTime(now); got != tt.want {
-				t.Errorf("shouldSplitByTime() = %v, want %v", got, tt.want)
+			if got := w.shouldRotate(now); got != tt.want {
+				t.Errorf("shouldRotate() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -0,0 +1,129 @@
+package log
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// ConcurrentWriter is implemented by writers which could be written by
+// several goroutines at the same time. Logger wraps the writers those do not
+// implement it with a lock, such as bytes.Buffer and bufio.Writer, so that
+// they never race. Implementing it only to skip the lock is the writer's own
+// risk.
+type ConcurrentWriter interface {
+	io.Writer
+
+	// ConcurrentSafe is a marker method, it's never called.
+	ConcurrentSafe()
+}
+
+// ConcurrentSafe marks fileWriter as ConcurrentWriter, it's locked by itself.
+func (w *fileWriter) ConcurrentSafe() {}
+
+// ConcurrentSafe marks FileRouter as ConcurrentWriter, it's locked by itself.
+func (r *FileRouter) ConcurrentSafe() {}
+
+// isConcurrentSafe reports whether w could be written concurrently without lock.
+func isConcurrentSafe(w io.Writer) bool {
+	switch w.(type) {
+	case ConcurrentWriter, *os.File, *lockedWriter:
+		return true
+	}
+
+	return w == ioutil.Discard
+}
+
+// lockedWriter serializes Write, Sync and Close of the writer which is not
+// safe for concurrent use.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// lockWriter wraps w with lock if it's not safe for concurrent use.
+func lockWriter(w io.Writer) io.Writer {
+	if isConcurrentSafe(w) {
+		return w
+	}
+
+	return &lockedWriter{w: w}
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	return lw.w.Write(p)
+}
+
+// Sync calls Sync of the wrapped writer if it's implemented.
+func (lw *lockedWriter) Sync() error {
+	s, ok := lw.w.(syncer)
+	if !ok {
+		return nil
+	}
+
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	return s.Sync()
+}
+
+// Close calls Close of the wrapped writer if it's implemented.
+func (lw *lockedWriter) Close() error {
+	c, ok := lw.w.(io.Closer)
+	if !ok {
+		return nil
+	}
+
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	return c.Close()
+}
+
+// multiWriter duplicates writes to all writers like io.MultiWriter, and it
+// passes fields to the writers those implement fieldsWriter, such as
+// FileRouter, so that they still route entries by fields.
+type multiWriter struct {
+	writers []io.Writer
+}
+
+var _ fieldsWriter = &multiWriter{}
+
+func newMultiWriter(writers ...io.Writer) *multiWriter {
+	return &multiWriter{writers: append([]io.Writer(nil), writers...)}
+}
+
+// ConcurrentSafe marks multiWriter as ConcurrentWriter, the writers have
+// been locked by options.addWriter.
+func (mw *multiWriter) ConcurrentSafe() {}
+
+func (mw *multiWriter) Write(p []byte) (int, error) {
+	return mw.writeFields(nil, p)
+}
+
+// writeFields stops at the first error like io.MultiWriter.
+func (mw *multiWriter) writeFields(fields Fields, p []byte) (int, error) {
+	for _, w := range mw.writers {
+		var (
+			n   int
+			err error
+		)
+		if fw, ok := w.(fieldsWriter); ok && fields != nil {
+			n, err = fw.writeFields(fields, p)
+		} else {
+			n, err = w.Write(p)
+		}
+		if err != nil {
+			return n, err
+		}
+		if n != len(p) {
+			return n, io.ErrShortWrite
+		}
+	}
+
+	return len(p), nil
+}
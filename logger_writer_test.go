@@ -0,0 +1,75 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// safeBuffer declares itself as ConcurrentWriter.
+type safeBuffer struct {
+	bytes.Buffer
+}
+
+func (b *safeBuffer) ConcurrentSafe() {}
+
+func Test_lockWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	_, ok := lockWriter(buf).(*lockedWriter)
+	assert.True(t, ok)
+
+	locked := lockWriter(buf)
+	assert.Same(t, locked, lockWriter(locked))
+
+	sb := &safeBuffer{}
+	assert.Same(t, sb, lockWriter(sb))
+	assert.Equal(t, os.Stdout, lockWriter(os.Stdout))
+	assert.Equal(t, ioutil.Discard, lockWriter(ioutil.Discard))
+}
+
+func Test_Logger_concurrentUnsafeWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	bw := bufio.NewWriterSize(buf, 64)
+	l, err := NewLogger(WithCustomWriter(bw))
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				l.WithField("g", strconv.Itoa(i)).Info("concurrent")
+			}
+		}(i)
+	}
+	wg.Wait()
+	require.NoError(t, bw.Flush())
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Len(t, lines, 800)
+	for _, line := range lines {
+		assert.Regexp(t, `^\[INF\] "\d+" Fields\{g="\d"\} concurrent$`, line)
+	}
+}
+
+func Test_lockedWriter_SyncClose(t *testing.T) {
+	w := &closableWriter{}
+	lw := lockWriter(w).(*lockedWriter)
+	assert.NoError(t, lw.Sync())
+	assert.NoError(t, lw.Close())
+	assert.Equal(t, 1, w.syncs)
+	assert.Equal(t, 1, w.closes)
+
+	plain := lockWriter(&bytes.Buffer{}).(*lockedWriter)
+	assert.NoError(t, plain.Sync())
+	assert.NoError(t, plain.Close())
+}
@@ -0,0 +1,101 @@
+package logtest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yeqown/log"
+)
+
+// New creates a logger which writes every entry through t.Logf, so that
+// the output is attributed to the test, and it's hidden unless the test
+// fails or `-v` is set. opts are applied after the defaults.
+//
+// t.Logf marks its line with the position in the log package, so the real
+// call site is printed at the start of every line instead.
+//
+// The logger must not be used after the test completes, it panics with the
+// leaked entry in that case, which usually means a goroutine is leaked.
+func New(t testing.TB, opts ...log.LoggerOption) *log.Logger {
+	t.Helper()
+
+	sink := &testingSink{t: t}
+	t.Cleanup(sink.complete)
+
+	in := make([]log.LoggerOption, 0, len(opts)+3)
+	in = append(in,
+		log.WithCustomWriter(ioutil.Discard),
+		log.WithReportCaller(true),
+		log.WithSinks(sink),
+	)
+	in = append(in, opts...)
+
+	logger, err := log.NewLogger(in...)
+	if err != nil {
+		t.Fatalf("logtest.New: could not create logger: %v", err)
+	}
+
+	return logger
+}
+
+// testingSink writes entries through t.Logf.
+type testingSink struct {
+	t testing.TB
+
+	mu        sync.RWMutex // guards completed against logging concurrently.
+	completed bool
+}
+
+func (s *testingSink) Emit(e *log.Entry) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.completed {
+		panic(fmt.Sprintf("logtest: log after %s has completed, maybe a goroutine is leaked: %s",
+			s.t.Name(), formatLine(e)))
+	}
+
+	s.t.Helper()
+	s.t.Logf("%s", formatLine(e))
+
+	return nil
+}
+
+func (s *testingSink) complete() {
+	s.mu.Lock()
+	s.completed = true
+	s.mu.Unlock()
+}
+
+// formatLine formats e as: `file.go:12: [INF] message key1=value1 key2=value2`.
+func formatLine(e *log.Entry) string {
+	var sb strings.Builder
+	if e.Caller != nil {
+		sb.WriteString(filepath.Base(e.Caller.File))
+		sb.WriteByte(':')
+		sb.WriteString(strconv.Itoa(e.Caller.Line))
+		sb.WriteString(": ")
+	}
+	sb.WriteString("[" + e.Level.String() + "] ")
+	sb.WriteString(e.Message)
+
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		sb.WriteByte(' ')
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(fmt.Sprintf("%+v", e.Fields[k]))
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,58 @@
+package logtest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yeqown/log"
+)
+
+// recordT records logs and cleanups of New.
+type recordT struct {
+	testing.TB
+	logs     []string
+	cleanups []func()
+}
+
+func (t *recordT) Helper()          {}
+func (t *recordT) Name() string     { return "TestRecord" }
+func (t *recordT) Cleanup(f func()) { t.cleanups = append(t.cleanups, f) }
+
+func (t *recordT) Logf(format string, args ...interface{}) {
+	t.logs = append(t.logs, fmt.Sprintf(format, args...))
+}
+
+func (t *recordT) complete() {
+	for _, f := range t.cleanups {
+		f()
+	}
+}
+
+func Test_New(t *testing.T) {
+	rt := &recordT{}
+	logger := New(rt, log.WithLevel(log.LevelInfo))
+
+	logger.Debug("filtered")
+	logger.WithFields(log.Fields{"b": 2, "a": "1"}).Info("hello")
+
+	require.Len(t, rt.logs, 1)
+	assert.Regexp(t, `^logger_test\.go:\d+: \[INF\] hello a=1 b=2$`, rt.logs[0])
+
+	// logging after test completed should panic.
+	rt.complete()
+	var recovered interface{}
+	func() {
+		defer func() { recovered = recover() }()
+		logger.WithField("k", "v").Error("leaked")
+	}()
+	assert.Regexp(t, `^logtest: log after TestRecord has completed, maybe a goroutine is leaked: `+
+		`logger_test\.go:\d+: \[ERR\] leaked k=v$`, recovered)
+}
+
+func Test_New_RealT(t *testing.T) {
+	logger := New(t)
+	logger.WithField("key", "value").Info("this line should be attributed to Test_New_RealT")
+}
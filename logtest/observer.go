@@ -0,0 +1,230 @@
+// Package logtest provides helpers to test code which logs by `log`.
+//
+// Observer captures structured entries, so assertions could be made on
+// level, message, fields, caller and context rather than on the text output.
+package logtest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yeqown/log"
+)
+
+// Observer is a log.Sink which captures every entry in memory.
+type Observer struct {
+	mu      sync.RWMutex
+	entries []log.Entry
+}
+
+var _ log.Sink = &Observer{}
+
+// NewObserver creates an empty Observer, it should be attached to a logger
+// by log.WithSinks.
+func NewObserver() *Observer {
+	return &Observer{}
+}
+
+// Observe creates a logger which captures entries into the returned Observer
+// rather than printing them. Caller is reported by default, and opts are
+// applied after the defaults so that they could be overwritten.
+func Observe(t testing.TB, opts ...log.LoggerOption) (*log.Logger, *Observer) {
+	t.Helper()
+
+	obs := NewObserver()
+	in := make([]log.LoggerOption, 0, len(opts)+3)
+	in = append(in,
+		log.WithCustomWriter(ioutil.Discard),
+		log.WithReportCaller(true),
+		log.WithSinks(obs),
+	)
+	in = append(in, opts...)
+
+	logger, err := log.NewLogger(in...)
+	if err != nil {
+		t.Fatalf("logtest.Observe: could not create logger: %v", err)
+	}
+
+	return logger, obs
+}
+
+// Emit captures e.
+func (o *Observer) Emit(e *log.Entry) error {
+	o.mu.Lock()
+	o.entries = append(o.entries, *e)
+	o.mu.Unlock()
+
+	return nil
+}
+
+// Len returns the count of captured entries.
+func (o *Observer) Len() int {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return len(o.entries)
+}
+
+// All returns a copy of captured entries in order.
+func (o *Observer) All() []log.Entry {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	out := make([]log.Entry, len(o.entries))
+	copy(out, o.entries)
+	return out
+}
+
+// TakeAll returns all captured entries and resets the Observer.
+func (o *Observer) TakeAll() []log.Entry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	out := o.entries
+	o.entries = nil
+	return out
+}
+
+// Messages returns messages of captured entries in order.
+func (o *Observer) Messages() []string {
+	entries := o.All()
+	out := make([]string, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, e.Message)
+	}
+
+	return out
+}
+
+// Filter returns a new Observer contains the entries those match fn,
+// Filter methods could be chained:
+//
+//	obs.FilterLevel(log.LevelError).FilterField("user_id", 1).Len()
+func (o *Observer) Filter(fn func(e log.Entry) bool) *Observer {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	filtered := &Observer{}
+	for _, e := range o.entries {
+		if fn(e) {
+			filtered.entries = append(filtered.entries, e)
+		}
+	}
+
+	return filtered
+}
+
+// FilterLevel filters entries by level exactly.
+func (o *Observer) FilterLevel(lv log.Level) *Observer {
+	return o.Filter(func(e log.Entry) bool {
+		return e.Level == lv
+	})
+}
+
+// FilterLevelAbove filters entries those are as severe as lv or more,
+// FilterLevelAbove(log.LevelError) gives errors and fatal entries.
+func (o *Observer) FilterLevelAbove(lv log.Level) *Observer {
+	return o.Filter(func(e log.Entry) bool {
+		return e.Level <= lv
+	})
+}
+
+// FilterMessage filters entries by message exactly.
+func (o *Observer) FilterMessage(msg string) *Observer {
+	return o.Filter(func(e log.Entry) bool {
+		return e.Message == msg
+	})
+}
+
+// FilterMessageSnippet filters entries those message contains snippet.
+func (o *Observer) FilterMessageSnippet(snippet string) *Observer {
+	return o.Filter(func(e log.Entry) bool {
+		return strings.Contains(e.Message, snippet)
+	})
+}
+
+// FilterField filters entries those have the field key equals to value.
+func (o *Observer) FilterField(key string, value interface{}) *Observer {
+	return o.Filter(func(e log.Entry) bool {
+		v, ok := e.Fields[key]
+		return ok && reflect.DeepEqual(v, value)
+	})
+}
+
+// FilterFieldKey filters entries those have the field key.
+func (o *Observer) FilterFieldKey(key string) *Observer {
+	return o.Filter(func(e log.Entry) bool {
+		_, ok := e.Fields[key]
+		return ok
+	})
+}
+
+// FilterContext filters entries those are logged with ctx.
+func (o *Observer) FilterContext(ctx context.Context) *Observer {
+	return o.Filter(func(e log.Entry) bool {
+		return e.Context == ctx
+	})
+}
+
+// AssertNoErrors fails t if any error or fatal entry is captured.
+func (o *Observer) AssertNoErrors(t testing.TB) bool {
+	t.Helper()
+
+	errs := o.FilterLevelAbove(log.LevelError).All()
+	if len(errs) == 0 {
+		return true
+	}
+
+	var sb strings.Builder
+	for _, e := range errs {
+		sb.WriteString("\n\t")
+		sb.WriteString(describe(e))
+	}
+	t.Errorf("logtest: %d error entries are logged:%s", len(errs), sb.String())
+
+	return false
+}
+
+// AssertLogged fails t if no entry with lv and msg is captured.
+func (o *Observer) AssertLogged(t testing.TB, lv log.Level, msg string) bool {
+	t.Helper()
+
+	if o.FilterLevel(lv).FilterMessage(msg).Len() != 0 {
+		return true
+	}
+	t.Errorf("logtest: entry [%s] %q is not logged, captured: %q", lv, msg, o.Messages())
+
+	return false
+}
+
+// AssertNotLogged fails t if any entry contains snippet in message is captured.
+func (o *Observer) AssertNotLogged(t testing.TB, snippet string) bool {
+	t.Helper()
+
+	matched := o.FilterMessageSnippet(snippet).All()
+	if len(matched) == 0 {
+		return true
+	}
+	t.Errorf("logtest: entry contains %q is logged: %s", snippet, describe(matched[0]))
+
+	return false
+}
+
+// describe formats e in one line for failure messages.
+func describe(e log.Entry) string {
+	s := "[" + e.Level.String() + "] " + e.Message
+	if len(e.Fields) != 0 {
+		s += fmt.Sprintf(" %+v", e.Fields)
+	}
+	if e.Caller != nil {
+		s += " (" + e.Caller.File + ":" + strconv.Itoa(e.Caller.Line) + ")"
+	}
+
+	return s
+}
@@ -0,0 +1,85 @@
+package logtest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yeqown/log"
+)
+
+// fakeT records failures instead of failing the test.
+type fakeT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func Test_Observe(t *testing.T) {
+	logger, obs := Observe(t, log.WithGlobalFields(log.Fields{"app": "test"}))
+
+	ctx := context.WithValue(context.Background(), struct{}{}, "v")
+	logger.Debug("debug")
+	logger.WithField("user_id", 1).Info("login")
+	logger.WithField("user_id", 2).Info("login")
+	logger.WithContext(ctx).Warn("slow")
+	logger.WithField("user_id", 1).Errorf("failed: %s", "timeout")
+
+	assert.Equal(t, 5, obs.Len())
+	assert.Equal(t, []string{"debug", "login", "login", "slow", "failed: timeout"}, obs.Messages())
+	assert.Equal(t, 2, obs.FilterLevel(log.LevelInfo).Len())
+	assert.Equal(t, 2, obs.FilterLevelAbove(log.LevelWarning).Len())
+	assert.Equal(t, 2, obs.FilterField("user_id", 1).Len())
+	assert.Equal(t, 1, obs.FilterLevel(log.LevelInfo).FilterField("user_id", 1).Len())
+	assert.Equal(t, 3, obs.FilterFieldKey("user_id").Len())
+	assert.Equal(t, 5, obs.FilterField("app", "test").Len())
+	assert.Equal(t, 1, obs.FilterMessageSnippet("timeout").Len())
+	assert.Equal(t, []string{"slow"}, obs.FilterContext(ctx).Messages())
+
+	// caller is reported by default.
+	entries := obs.All()
+	require.NotNil(t, entries[0].Caller)
+	assert.True(t, strings.HasSuffix(entries[0].Caller.File, "observer_test.go"))
+	assert.Contains(t, entries[0].Caller.Function, "Test_Observe")
+
+	taken := obs.TakeAll()
+	assert.Len(t, taken, 5)
+	assert.Equal(t, 0, obs.Len())
+}
+
+func Test_Observer_Assertions(t *testing.T) {
+	logger, obs := Observe(t)
+	logger.Info("started")
+
+	ft := &fakeT{}
+	assert.True(t, obs.AssertNoErrors(ft))
+	assert.True(t, obs.AssertLogged(ft, log.LevelInfo, "started"))
+	assert.True(t, obs.AssertNotLogged(ft, "password"))
+	assert.Empty(t, ft.errors)
+
+	logger.WithField("password", "***").Error("password mismatch")
+	assert.False(t, obs.AssertNoErrors(ft))
+	assert.False(t, obs.AssertLogged(ft, log.LevelWarning, "started"))
+	assert.False(t, obs.AssertNotLogged(ft, "password"))
+	require.Len(t, ft.errors, 3)
+	assert.Contains(t, ft.errors[0], "1 error entries are logged")
+	assert.Contains(t, ft.errors[0], "[ERR] password mismatch")
+	assert.Contains(t, ft.errors[0], "observer_test.go")
+}
+
+func Test_Observer_WithLevel(t *testing.T) {
+	logger, obs := Observe(t, log.WithLevel(log.LevelWarning))
+	logger.Info("filtered")
+	logger.Warn("kept")
+
+	assert.Equal(t, []string{"kept"}, obs.Messages())
+}
@@ -0,0 +1,386 @@
+package log
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// msgpackExt is an extension value of msgpack.
+type msgpackExt struct {
+	Type int8
+	Data []byte
+}
+
+// appendMsgpack appends v encoded in msgpack to b. Only the types those
+// could be logged commonly are supported natively, the others would be
+// formatted as string.
+//
+// https://github.com/msgpack/msgpack/blob/master/spec.md
+func appendMsgpack(b []byte, v interface{}) []byte {
+	switch vv := v.(type) {
+	case nil:
+		return append(b, 0xc0)
+	case bool:
+		if vv {
+			return append(b, 0xc3)
+		}
+		return append(b, 0xc2)
+	case int:
+		return appendMsgpackInt(b, int64(vv))
+	case int8:
+		return appendMsgpackInt(b, int64(vv))
+	case int16:
+		return appendMsgpackInt(b, int64(vv))
+	case int32:
+		return appendMsgpackInt(b, int64(vv))
+	case int64:
+		return appendMsgpackInt(b, vv)
+	case uint:
+		return appendMsgpackUint(b, uint64(vv))
+	case uint8:
+		return appendMsgpackUint(b, uint64(vv))
+	case uint16:
+		return appendMsgpackUint(b, uint64(vv))
+	case uint32:
+		return appendMsgpackUint(b, uint64(vv))
+	case uint64:
+		return appendMsgpackUint(b, vv)
+	case float32:
+		b = append(b, 0xca)
+		return appendUint32(b, math.Float32bits(vv))
+	case float64:
+		b = append(b, 0xcb)
+		return appendUint64(b, math.Float64bits(vv))
+	case string:
+		return appendMsgpackString(b, vv)
+	case []byte:
+		return appendMsgpackBin(b, vv)
+	case time.Time:
+		return appendMsgpackString(b, vv.Format(time.RFC3339Nano))
+	case time.Duration:
+		return appendMsgpackString(b, vv.String())
+	case error:
+		return appendMsgpackString(b, vv.Error())
+	case fmt.Stringer:
+		return appendMsgpackString(b, vv.String())
+	case msgpackExt:
+		return appendMsgpackExt(b, vv.Type, vv.Data)
+	case Fields:
+		return appendMsgpackMap(b, vv)
+	case map[string]interface{}:
+		return appendMsgpackMap(b, vv)
+	case []interface{}:
+		b = appendMsgpackArrayHeader(b, len(vv))
+		for _, elem := range vv {
+			b = appendMsgpack(b, elem)
+		}
+		return b
+	}
+
+	// slices and arrays of other types are encoded as array,
+	// all the others are formatted as string.
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		b = appendMsgpackArrayHeader(b, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			b = appendMsgpack(b, rv.Index(i).Interface())
+		}
+		return b
+	}
+
+	return appendMsgpackString(b, fmt.Sprintf(_interfaceFormat, v))
+}
+
+func appendMsgpackInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0:
+		return appendMsgpackUint(b, uint64(v))
+	case v >= -32:
+		return append(b, byte(v))
+	case v >= math.MinInt8:
+		return append(b, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		return appendUint16(append(b, 0xd1), uint16(v))
+	case v >= math.MinInt32:
+		return appendUint32(append(b, 0xd2), uint32(v))
+	}
+
+	return appendUint64(append(b, 0xd3), uint64(v))
+}
+
+func appendMsgpackUint(b []byte, v uint64) []byte {
+	switch {
+	case v < 128:
+		return append(b, byte(v))
+	case v <= math.MaxUint8:
+		return append(b, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		return appendUint16(append(b, 0xcd), uint16(v))
+	case v <= math.MaxUint32:
+		return appendUint32(append(b, 0xce), uint32(v))
+	}
+
+	return appendUint64(append(b, 0xcf), v)
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = appendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = appendUint32(append(b, 0xdb), uint32(n))
+	}
+
+	return append(b, s...)
+}
+
+func appendMsgpackBin(b []byte, data []byte) []byte {
+	n := len(data)
+	switch {
+	case n <= math.MaxUint8:
+		b = append(b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		b = appendUint16(append(b, 0xc5), uint16(n))
+	default:
+		b = appendUint32(append(b, 0xc6), uint32(n))
+	}
+
+	return append(b, data...)
+}
+
+func appendMsgpackArrayHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return appendUint16(append(b, 0xdc), uint16(n))
+	}
+
+	return appendUint32(append(b, 0xdd), uint32(n))
+}
+
+func appendMsgpackMapHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return appendUint16(append(b, 0xde), uint16(n))
+	}
+
+	return appendUint32(append(b, 0xdf), uint32(n))
+}
+
+func appendMsgpackMap(b []byte, m map[string]interface{}) []byte {
+	b = appendMsgpackMapHeader(b, len(m))
+	for k, v := range m {
+		b = appendMsgpackString(b, k)
+		b = appendMsgpack(b, v)
+	}
+
+	return b
+}
+
+func appendMsgpackExt(b []byte, typ int8, data []byte) []byte {
+	n := len(data)
+	switch n {
+	case 1:
+		b = append(b, 0xd4)
+	case 2:
+		b = append(b, 0xd5)
+	case 4:
+		b = append(b, 0xd6)
+	case 8:
+		b = append(b, 0xd7)
+	case 16:
+		b = append(b, 0xd8)
+	default:
+		switch {
+		case n <= math.MaxUint8:
+			b = append(b, 0xc7, byte(n))
+		case n <= math.MaxUint16:
+			b = appendUint16(append(b, 0xc8), uint16(n))
+		default:
+			b = appendUint32(append(b, 0xc9), uint32(n))
+		}
+	}
+
+	b = append(b, byte(typ))
+	return append(b, data...)
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return append(b, byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32),
+		byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// readMsgpack reads one msgpack value from r. Maps are decoded into
+// map[string]interface{} (non-string keys are formatted), arrays into
+// []interface{}, integers into int64 or uint64, str into string,
+// bin into []byte and ext into msgpackExt.
+func readMsgpack(r *bufio.Reader) (interface{}, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xe0 == 0xa0:
+		return readMsgpackString(r, int(c&0x1f))
+	case c&0xf0 == 0x90:
+		return readMsgpackArray(r, int(c&0x0f))
+	case c&0xf0 == 0x80:
+		return readMsgpackMap(r, int(c&0x0f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		v, err := readMsgpackN(r, 1<<(c-0xcc))
+		return v, err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		v, err := readMsgpackN(r, size)
+		shift := uint(64 - 8*size)
+		return int64(v<<shift) >> shift, err
+	case 0xca:
+		v, err := readMsgpackN(r, 4)
+		return math.Float32frombits(uint32(v)), err
+	case 0xcb:
+		v, err := readMsgpackN(r, 8)
+		return math.Float64frombits(v), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := readMsgpackN(r, 1<<(c-0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackString(r, int(n))
+	case 0xc4, 0xc5, 0xc6:
+		n, err := readMsgpackN(r, 1<<(c-0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackBytes(r, int(n))
+	case 0xdc, 0xdd:
+		n, err := readMsgpackN(r, 2<<(c-0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackArray(r, int(n))
+	case 0xde, 0xdf:
+		n, err := readMsgpackN(r, 2<<(c-0xde))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackMap(r, int(n))
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return readMsgpackExt(r, 1<<(c-0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		n, err := readMsgpackN(r, 1<<(c-0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackExt(r, int(n))
+	}
+
+	return nil, errors.Errorf("msgpack: unknown format 0x%x", c)
+}
+
+// readMsgpackN reads a big-endian unsigned integer in size bytes.
+func readMsgpackN(r *bufio.Reader, size int) (uint64, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(r, buf[8-size:]); err != nil {
+		return 0, err
+	}
+
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
+
+func readMsgpackBytes(r *bufio.Reader, n int) ([]byte, error) {
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func readMsgpackString(r *bufio.Reader, n int) (interface{}, error) {
+	data, err := readMsgpackBytes(r, n)
+	return string(data), err
+}
+
+func readMsgpackArray(r *bufio.Reader, n int) (interface{}, error) {
+	arr := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		v, err := readMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+	}
+
+	return arr, nil
+}
+
+func readMsgpackMap(r *bufio.Reader, n int) (interface{}, error) {
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := readMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		v, err := readMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			key = fmt.Sprint(k)
+		}
+		m[key] = v
+	}
+
+	return m, nil
+}
+
+func readMsgpackExt(r *bufio.Reader, n int) (interface{}, error) {
+	typ, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	data, err := readMsgpackBytes(r, n)
+	if err != nil {
+		return nil, err
+	}
+
+	return msgpackExt{Type: int8(typ), Data: data}, nil
+}
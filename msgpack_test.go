@@ -0,0 +1,53 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_msgpack_roundtrip(t *testing.T) {
+	tests := []struct {
+		name string
+		in   interface{}
+		want interface{}
+	}{
+		{name: "nil", in: nil, want: nil},
+		{name: "bool", in: true, want: true},
+		{name: "fixint", in: 7, want: int64(7)},
+		{name: "negative fixint", in: -3, want: int64(-3)},
+		{name: "int8", in: -100, want: int64(-100)},
+		{name: "int16", in: -1000, want: int64(-1000)},
+		{name: "int32", in: int32(-100000), want: int64(-100000)},
+		{name: "int64", in: int64(math.MinInt64), want: int64(math.MinInt64)},
+		{name: "uint8", in: uint8(200), want: uint64(200)},
+		{name: "uint16", in: 60000, want: uint64(60000)},
+		{name: "uint64", in: uint64(math.MaxUint64), want: uint64(math.MaxUint64)},
+		{name: "float64", in: 1.5, want: 1.5},
+		{name: "fixstr", in: "abc", want: "abc"},
+		{name: "str8", in: strings.Repeat("a", 100), want: strings.Repeat("a", 100)},
+		{name: "str16", in: strings.Repeat("a", 1000), want: strings.Repeat("a", 1000)},
+		{name: "bin", in: []byte{1, 2, 3}, want: []byte{1, 2, 3}},
+		{name: "error", in: errors.New("oops"), want: "oops"},
+		{name: "duration", in: time.Second, want: "1s"},
+		{name: "slice", in: []string{"a", "b"}, want: []interface{}{"a", "b"}},
+		{name: "map", in: Fields{"k": []interface{}{1, "v"}}, want: map[string]interface{}{"k": []interface{}{int64(1), "v"}}},
+		{name: "struct", in: struct{ A int }{A: 1}, want: "{A:1}"},
+		{name: "ext", in: msgpackExt{Type: 0, Data: make([]byte, 8)}, want: msgpackExt{Type: 0, Data: make([]byte, 8)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := appendMsgpack(nil, tt.in)
+			got, err := readMsgpack(bufio.NewReader(bytes.NewReader(b)))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// errSinkClosed would be returned if the sink has been closed.
+	errSinkClosed = errors.New("sink has been closed")
+	// errSinkBufferFull would be returned if the sink could not accept any
+	// more entries, the entry would be dropped.
+	errSinkBufferFull = errors.New("sink buffer is full, entry dropped")
+)
+
+const (
+	_defaultBatchSize   = 512
+	_defaultBatchWait   = time.Second
+	_defaultMaxRetries  = 3
+	_defaultBackoff     = 500 * time.Millisecond
+	_defaultMaxBackoff  = 30 * time.Second
+	_defaultHTTPTimeout = 10 * time.Second
+)
+
+// batcher collects items from Emit calls and flushes them in its own
+// goroutine when size items are collected or every wait duration.
+type batcher struct {
+	size  int
+	wait  time.Duration
+	flush func(items []interface{})
+
+	mu     sync.RWMutex // guards ch against sending after closed.
+	closed bool
+	ch     chan interface{}
+	syncCh chan chan struct{} // request to flush pending items immediately.
+	done   chan struct{}
+}
+
+func newBatcher(size int, wait time.Duration, flush func(items []interface{})) *batcher {
+	if size <= 0 {
+		size = _defaultBatchSize
+	}
+	if wait <= 0 {
+		wait = _defaultBatchWait
+	}
+
+	b := &batcher{
+		size:   size,
+		wait:   wait,
+		flush:  flush,
+		ch:     make(chan interface{}, size*4),
+		syncCh: make(chan chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go b.run()
+
+	return b
+}
+
+// add an item into batcher, it never blocks. errSinkBufferFull would be
+// returned if the backend is too slow to consume.
+func (b *batcher) add(item interface{}) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		return errSinkClosed
+	}
+
+	select {
+	case b.ch <- item:
+		return nil
+	default:
+		return errSinkBufferFull
+	}
+}
+
+// sync flushes the items those have been added, and waits until done.
+func (b *batcher) sync() {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return
+	}
+	flushed := make(chan struct{})
+	b.syncCh <- flushed
+	b.mu.RUnlock()
+
+	<-flushed
+}
+
+// close stops accepting items, and waits for the pending items to be flushed.
+func (b *batcher) close() {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.ch)
+	}
+	b.mu.Unlock()
+
+	<-b.done
+}
+
+func (b *batcher) run() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.wait)
+	defer ticker.Stop()
+
+	items := make([]interface{}, 0, b.size)
+	for {
+		select {
+		case item, ok := <-b.ch:
+			if !ok {
+				if len(items) != 0 {
+					b.flush(items)
+				}
+				return
+			}
+
+			items = append(items, item)
+			if len(items) >= b.size {
+				b.flush(items)
+				items = make([]interface{}, 0, b.size)
+			}
+		case <-ticker.C:
+			if len(items) != 0 {
+				b.flush(items)
+				items = make([]interface{}, 0, b.size)
+			}
+		case flushed := <-b.syncCh:
+			// drain the items those have been queued before sync.
+			for n := len(b.ch); n > 0; n-- {
+				items = append(items, <-b.ch)
+			}
+			if len(items) != 0 {
+				b.flush(items)
+				items = make([]interface{}, 0, b.size)
+			}
+			close(flushed)
+		}
+	}
+}
+
+// sinkReporter reports the errors of batched sink, which occur in the
+// goroutine of batcher, to the ErrorHandler of the Logger it's bound to.
+type sinkReporter struct {
+	mu     sync.Mutex
+	logger *Logger
+}
+
+// bind attaches the sink to l, errors are reported to the first bound Logger.
+func (r *sinkReporter) bind(l *Logger) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.logger == nil {
+		r.logger = l
+	}
+}
+
+// report hands err to the ErrorHandler with ErrorKindSink, or prints it by
+// defaultErrorHandler if the sink has not been bound.
+func (r *sinkReporter) report(err error) {
+	r.mu.Lock()
+	logger := r.logger
+	r.mu.Unlock()
+
+	if logger == nil {
+		defaultErrorHandler(ErrorKindSink, err)
+		return
+	}
+	logger.handleError(ErrorKindSink, err)
+}
+
+// retryPolicy describes how many times and how long to wait before resending.
+type retryPolicy struct {
+	max        int           // max retry times, 0 means never retry.
+	backoff    time.Duration // the first backoff, doubled after every failure.
+	maxBackoff time.Duration // upper limit of backoff.
+}
+
+func defaultRetryPolicy() retryPolicy {
+	return retryPolicy{
+		max:        _defaultMaxRetries,
+		backoff:    _defaultBackoff,
+		maxBackoff: _defaultMaxBackoff,
+	}
+}
+
+// do calls fn until it succeeds, returns an unretryable error
+// or the retry times is exhausted.
+func (p retryPolicy) do(fn func() error) error {
+	backoff := p.backoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.max || !isRetryable(err) {
+			return err
+		}
+
+		time.Sleep(backoff)
+		if backoff *= 2; p.maxBackoff > 0 && backoff > p.maxBackoff {
+			backoff = p.maxBackoff
+		}
+	}
+}
+
+// httpStatusError means the server responded with an unexpected status code.
+type httpStatusError struct {
+	code int
+	body string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d, body: %s", e.code, e.body)
+}
+
+// isRetryable reports whether err is temporary. Client errors (4xx) except
+// 429 are permanent, resending would not help.
+func isRetryable(err error) bool {
+	statusErr, ok := errors.Cause(err).(*httpStatusError)
+	if !ok {
+		return true
+	}
+
+	return statusErr.code == http.StatusTooManyRequests || statusErr.code >= 500
+}
+
+// postHTTP posts body to url and returns the response body, a *httpStatusError
+// would be returned if the status code is not 2xx.
+func postHTTP(client *http.Client, url string, header http.Header, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "postHTTP.NewRequest")
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "postHTTP.Do")
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, errors.Wrap(err, "postHTTP.ReadAll")
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return respBody, &httpStatusError{code: resp.StatusCode, body: string(respBody)}
+	}
+
+	return respBody, nil
+}
+
+// marshalJSON marshals m into JSON, values those could not be marshaled
+// (func, chan and so on) would be formatted as string instead of failing.
+func marshalJSON(m map[string]interface{}) []byte {
+	for k, v := range m {
+		if err, ok := v.(error); ok {
+			m[k] = err.Error()
+		}
+	}
+
+	data, err := json.Marshal(m)
+	if err == nil {
+		return data
+	}
+
+	for k, v := range m {
+		if _, err = json.Marshal(v); err != nil {
+			m[k] = fmt.Sprintf(_interfaceFormat, v)
+		}
+	}
+	data, _ = json.Marshal(m)
+
+	return data
+}
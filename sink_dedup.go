@@ -0,0 +1,136 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const _dedupRepeatedKey = "repeated"
+
+// DedupSink collapses repeated consecutive entries in the style of syslog's
+// "last message repeated N times". Entries with the same level, message and
+// fields as the last one are swallowed, and a summary entry is emitted into
+// next when a different entry arrives or timeout elapses after the first
+// repeat. It wraps one sink, so that the others could keep every entry.
+type DedupSink struct {
+	next    Sink
+	timeout time.Duration
+
+	mu       sync.Mutex
+	last     *Entry
+	repeated int
+	lastTime time.Time   // time of the last repeated entry
+	timer    *time.Timer // flushes the summary after timeout
+	timerGen uint64      // generation of timer, stale callbacks are ignored
+}
+
+var _ Sink = &DedupSink{}
+
+// NewDedupSink creates a DedupSink wraps next, summary would be emitted at
+// least every timeout while repeating, 0 means only flushing when a different
+// entry arrives.
+func NewDedupSink(next Sink, timeout time.Duration) *DedupSink {
+	return &DedupSink{
+		next:    next,
+		timeout: timeout,
+	}
+}
+
+// Emit swallows e if it repeats the last entry, otherwise flushes the summary
+// of last entry and emits e into next.
+func (s *DedupSink) Emit(e *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.last != nil && sameEntry(s.last, e) {
+		s.repeated++
+		s.lastTime = e.Time
+		if s.repeated == 1 && s.timeout > 0 {
+			s.timerGen++
+			gen := s.timerGen
+			s.timer = time.AfterFunc(s.timeout, func() { s.flushOnTimeout(gen) })
+		}
+		return nil
+	}
+
+	err := s.flushLocked()
+	s.last = e
+	if err2 := s.next.Emit(e); err2 != nil {
+		err = err2
+	}
+
+	return err
+}
+
+// flushOnTimeout flushes the summary if the timer of gen is still the current
+// one, Stop could not cancel a callback which has fired and waits for the lock.
+func (s *DedupSink) flushOnTimeout(gen uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.timer == nil || gen != s.timerGen {
+		return
+	}
+	_ = s.flushLocked()
+}
+
+// flushLocked emits the summary of repeated entries if there is, the last
+// entry is kept, so the following repeats are counted again.
+func (s *DedupSink) flushLocked() error {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	if s.repeated == 0 {
+		return nil
+	}
+
+	summary := &Entry{
+		Level:   s.last.Level,
+		Message: fmt.Sprintf("last message repeated %d times", s.repeated),
+		Time:    s.lastTime,
+		Fields:  Fields{_dedupRepeatedKey: s.repeated},
+	}
+	s.repeated = 0
+
+	return s.next.Emit(summary)
+}
+
+// sameEntry reports whether b repeats a, time, caller and context are ignored.
+func sameEntry(a, b *Entry) bool {
+	return a.Level == b.Level && a.Message == b.Message && reflect.DeepEqual(a.Fields, b.Fields)
+}
+
+// Sync flushes the summary, and calls Sync of next if it's implemented.
+func (s *DedupSink) Sync() error {
+	s.mu.Lock()
+	err := s.flushLocked()
+	s.mu.Unlock()
+
+	if sy, ok := s.next.(syncer); ok {
+		if err2 := sy.Sync(); err2 != nil {
+			err = err2
+		}
+	}
+
+	return err
+}
+
+// Close flushes the summary, and calls Close of next if it's implemented.
+func (s *DedupSink) Close() error {
+	s.mu.Lock()
+	err := s.flushLocked()
+	s.last = nil
+	s.mu.Unlock()
+
+	if c, ok := s.next.(io.Closer); ok {
+		if err2 := c.Close(); err2 != nil {
+			err = err2
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,127 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncSink collects emitted entries safely.
+type syncSink struct {
+	mu      sync.Mutex
+	entries []*Entry
+}
+
+func (s *syncSink) Emit(e *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, e)
+	return nil
+}
+
+func (s *syncSink) messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msgs := make([]string, 0, len(s.entries))
+	for _, e := range s.entries {
+		msgs = append(msgs, e.Message)
+	}
+	return msgs
+}
+
+func Test_DedupSink(t *testing.T) {
+	deduped := &syncSink{}
+	all := &syncSink{}
+	l, err := NewLogger(
+		WithCustomWriter(&bytes.Buffer{}),
+		WithSinks(NewDedupSink(deduped, 0), all),
+	)
+	require.NoError(t, err)
+
+	l.Info("connecting")
+	for i := 0; i < 3; i++ {
+		l.Warn("retry")
+	}
+	l.WithField("n", 1).Warn("retry")
+	l.WithField("n", 1).Warn("retry")
+	l.Error("retry")
+
+	assert.Equal(t, []string{
+		"connecting",
+		"retry",
+		"last message repeated 2 times",
+		"retry",
+		"last message repeated 1 times",
+		"retry",
+	}, deduped.messages())
+	assert.Len(t, all.messages(), 7)
+
+	summary := deduped.entries[2]
+	assert.Equal(t, LevelWarning, summary.Level)
+	assert.Equal(t, Fields{_dedupRepeatedKey: 2}, summary.Fields)
+}
+
+func Test_DedupSink_timeout(t *testing.T) {
+	next := &syncSink{}
+	s := NewDedupSink(next, 20*time.Millisecond)
+
+	e := &Entry{Level: LevelInfo, Message: "tick", Fields: Fields{}}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.Emit(e))
+	}
+	assert.Eventually(t, func() bool {
+		msgs := next.messages()
+		return len(msgs) == 2 && msgs[1] == "last message repeated 2 times"
+	}, time.Second, 5*time.Millisecond)
+
+	// repeats are counted again after the summary.
+	require.NoError(t, s.Emit(e))
+	require.NoError(t, s.Close())
+	assert.Equal(t, []string{"tick", "last message repeated 2 times", "last message repeated 1 times"}, next.messages())
+}
+
+func Test_DedupSink_staleTimer(t *testing.T) {
+	next := &syncSink{}
+	s := NewDedupSink(next, time.Hour)
+
+	a := &Entry{Level: LevelInfo, Message: "a", Fields: Fields{}}
+	b := &Entry{Level: LevelInfo, Message: "b", Fields: Fields{}}
+	require.NoError(t, s.Emit(a))
+	require.NoError(t, s.Emit(a))
+	s.mu.Lock()
+	stale := s.timerGen
+	s.mu.Unlock()
+
+	// a new streak starts after the timer of the last streak has fired.
+	require.NoError(t, s.Emit(b))
+	require.NoError(t, s.Emit(b))
+	s.flushOnTimeout(stale)
+	assert.Equal(t, []string{"a", "last message repeated 1 times", "b"}, next.messages())
+
+	require.NoError(t, s.Sync())
+	assert.Equal(t, []string{"a", "last message repeated 1 times", "b", "last message repeated 1 times"}, next.messages())
+}
+
+func Test_WriterSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := NewWriterSink(buf)
+	require.NoError(t, s.Emit(&Entry{Level: LevelInfo, Message: "hello", Fields: Fields{"b": 2, "a": 1}, Time: time.Unix(1, 0)}))
+	assert.Equal(t, "[INF] \"1\" Fields{a=\"1\" b=\"2\"} hello\n", buf.String())
+	assert.NoError(t, s.Sync())
+	assert.NoError(t, s.Close())
+
+	dedup := NewDedupSink(NewWriterSink(buf), 0)
+	buf.Reset()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, dedup.Emit(&Entry{Level: LevelInfo, Message: "same", Time: time.Unix(1, 0)}))
+	}
+	require.NoError(t, dedup.Sync())
+	assert.Equal(t, 2, strings.Count(buf.String(), "\n"))
+	assert.Contains(t, buf.String(), "last message repeated 2 times")
+}
@@ -0,0 +1,322 @@
+package log
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	_esBulkPath     = "/_bulk"
+	_esDefaultIndex = "logs-{2006.01.02}"
+	_esECSVersion   = "1.6.0"
+	_esTimestampKey = "@timestamp"
+	_esMessageKey   = "message"
+	_esLogKey       = "log"
+	_esECSKey       = "ecs"
+	_esContentType  = "application/x-ndjson"
+)
+
+// ElasticsearchOption to apply single function into `eo`.
+type ElasticsearchOption func(eo *elasticsearchOptions) error
+
+type elasticsearchOptions struct {
+	index    string // index name pattern
+	username string
+	password string
+	client   *http.Client
+
+	batchSize int
+	batchWait time.Duration
+	retry     retryPolicy
+}
+
+// WithElasticsearchIndex sets the index name pattern, the part inside braces
+// is a time layout which would be formatted by entry's time in UTC. For
+// example, `logs-{2006.01.02}` gives `logs-2026.10.17`.
+func WithElasticsearchIndex(pattern string) ElasticsearchOption {
+	return func(eo *elasticsearchOptions) error {
+		if pattern == "" {
+			return errors.New("WithElasticsearchIndex: empty pattern")
+		}
+		if strings.Count(pattern, "{") != strings.Count(pattern, "}") {
+			return errors.Errorf("WithElasticsearchIndex: unbalanced braces in pattern: %s", pattern)
+		}
+		eo.index = pattern
+		return nil
+	}
+}
+
+// WithElasticsearchBasicAuth sets the username and password of basic auth.
+func WithElasticsearchBasicAuth(username, password string) ElasticsearchOption {
+	return func(eo *elasticsearchOptions) error {
+		eo.username = username
+		eo.password = password
+		return nil
+	}
+}
+
+// WithElasticsearchBatch sets max documents count in one bulk request and
+// the max duration a document would wait before being sent.
+func WithElasticsearchBatch(size int, wait time.Duration) ElasticsearchOption {
+	return func(eo *elasticsearchOptions) error {
+		eo.batchSize = size
+		eo.batchWait = wait
+		return nil
+	}
+}
+
+// WithElasticsearchRetry sets max retry times and the first backoff duration,
+// only the failed documents would be resent when retrying.
+func WithElasticsearchRetry(max int, backoff time.Duration) ElasticsearchOption {
+	return func(eo *elasticsearchOptions) error {
+		eo.retry.max = max
+		eo.retry.backoff = backoff
+		return nil
+	}
+}
+
+// WithElasticsearchHTTPClient sets a custom http.Client to send bulk requests.
+func WithElasticsearchHTTPClient(client *http.Client) ElasticsearchOption {
+	return func(eo *elasticsearchOptions) error {
+		if client == nil {
+			return errors.New("WithElasticsearchHTTPClient: nil client")
+		}
+		eo.client = client
+		return nil
+	}
+}
+
+// ElasticsearchSink converts entries into ECS-style documents, and writes them
+// to Elasticsearch or OpenSearch through `_bulk` API in batch.
+type ElasticsearchSink struct {
+	opt     *elasticsearchOptions
+	url     string
+	header  http.Header
+	batcher *batcher
+
+	sinkReporter
+}
+
+var (
+	_ Sink         = &ElasticsearchSink{}
+	_ loggerBinder = &ElasticsearchSink{}
+)
+
+// NewElasticsearchSink creates an ElasticsearchSink writes to addr, addr
+// should be the base address of cluster like `http://es:9200`.
+func NewElasticsearchSink(addr string, opts ...ElasticsearchOption) (*ElasticsearchSink, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "NewElasticsearchSink.Parse addr: %s", addr)
+	}
+	if !strings.HasSuffix(u.Path, _esBulkPath) {
+		u.Path = strings.TrimSuffix(u.Path, "/") + _esBulkPath
+	}
+
+	eo := &elasticsearchOptions{
+		index:  _esDefaultIndex,
+		client: &http.Client{Timeout: _defaultHTTPTimeout},
+		retry:  defaultRetryPolicy(),
+	}
+	for _, opt := range opts {
+		if err = opt(eo); err != nil {
+			return nil, errors.Wrap(err, "failed to apply option")
+		}
+	}
+
+	header := make(http.Header)
+	header.Set("Content-Type", _esContentType)
+	if eo.username != "" || eo.password != "" {
+		auth := base64.StdEncoding.EncodeToString([]byte(eo.username + ":" + eo.password))
+		header.Set("Authorization", "Basic "+auth)
+	}
+
+	s := &ElasticsearchSink{
+		opt:    eo,
+		url:    u.String(),
+		header: header,
+	}
+	s.batcher = newBatcher(eo.batchSize, eo.batchWait, s.bulk)
+
+	return s, nil
+}
+
+// esDocument is an entry which has been converted into ECS document.
+type esDocument struct {
+	index string
+	body  []byte
+}
+
+// Emit converts e into ECS document, and queues it to be sent.
+func (s *ElasticsearchSink) Emit(e *Entry) error {
+	return s.batcher.add(esDocument{
+		index: formatIndexName(s.opt.index, e.Time),
+		body:  encodeECSDocument(e),
+	})
+}
+
+// Sync pushes the pending entries immediately.
+func (s *ElasticsearchSink) Sync() error {
+	s.batcher.sync()
+	return nil
+}
+
+// Close stops accepting entries and sends the pending documents.
+func (s *ElasticsearchSink) Close() error {
+	s.batcher.close()
+	return nil
+}
+
+func (s *ElasticsearchSink) bulk(items []interface{}) {
+	pending := make([]esDocument, 0, len(items))
+	for _, item := range items {
+		pending = append(pending, item.(esDocument))
+	}
+
+	err := s.opt.retry.do(func() error {
+		resp, err := postHTTP(s.opt.client, s.url, s.header, encodeBulkBody(pending))
+		if err != nil {
+			return err
+		}
+
+		retryable, rejected, err := parseBulkResponse(resp, pending)
+		if err != nil {
+			return errors.Wrap(err, "parseBulkResponse")
+		}
+		for _, reason := range rejected {
+			s.report(errors.Errorf("elasticsearch rejected document, reason=%s", reason))
+		}
+
+		// only resend the failed documents.
+		if pending = retryable; len(pending) != 0 {
+			return errors.Errorf("%d documents failed", len(pending))
+		}
+		return nil
+	})
+	if err != nil {
+		s.report(errors.Wrapf(err, "write %d documents to elasticsearch", len(pending)))
+	}
+}
+
+// encodeBulkBody encodes documents as `_bulk` NDJSON body:
+// {"index":{"_index":"logs-2026.10.17"}}
+// {"@timestamp":"...","message":"..."}
+func encodeBulkBody(docs []esDocument) []byte {
+	b := bytes.NewBuffer(make([]byte, 0, len(docs)*256))
+	for _, doc := range docs {
+		b.WriteString(`{"index":{"_index":`)
+		index, _ := json.Marshal(doc.index)
+		b.Write(index)
+		b.WriteString("}}\n")
+		b.Write(doc.body)
+		b.WriteByte('\n')
+	}
+
+	return b.Bytes()
+}
+
+// bulkResponse is the part of `_bulk` response which is cared.
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  *struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// parseBulkResponse picks the failed documents out. Documents failed with
+// 429 or 5xx are retryable, others are rejected and the reasons are returned.
+func parseBulkResponse(data []byte, docs []esDocument) (retryable []esDocument, rejected []string, err error) {
+	var resp bulkResponse
+	if err = json.Unmarshal(data, &resp); err != nil {
+		return nil, nil, err
+	}
+	if !resp.Errors {
+		return nil, nil, nil
+	}
+	if len(resp.Items) != len(docs) {
+		return nil, nil, errors.Errorf("items count mismatch, want %d, got %d", len(docs), len(resp.Items))
+	}
+
+	for i, item := range resp.Items {
+		for _, result := range item {
+			if result.Status >= 200 && result.Status < 300 {
+				continue
+			}
+			if result.Status == http.StatusTooManyRequests || result.Status >= 500 {
+				retryable = append(retryable, docs[i])
+				continue
+			}
+
+			reason := http.StatusText(result.Status)
+			if result.Error != nil {
+				reason = result.Error.Type + ": " + result.Error.Reason
+			}
+			rejected = append(rejected, reason)
+		}
+	}
+
+	return retryable, rejected, nil
+}
+
+// encodeECSDocument converts e into ECS-style document. Fields are kept in top
+// level, and they could not override the ECS fields.
+func encodeECSDocument(e *Entry) []byte {
+	doc := make(map[string]interface{}, len(e.Fields)+4)
+	for k, v := range e.Fields {
+		doc[k] = v
+	}
+
+	logField := map[string]interface{}{
+		"level": e.Level.name(),
+	}
+	if e.Caller != nil {
+		logField["origin"] = map[string]interface{}{
+			"file": map[string]interface{}{
+				"name": e.Caller.File,
+				"line": e.Caller.Line,
+			},
+			"function": e.Caller.Function,
+		}
+	}
+
+	doc[_esTimestampKey] = e.Time.UTC().Format(time.RFC3339Nano)
+	doc[_esMessageKey] = e.Message
+	doc[_esLogKey] = logField
+	doc[_esECSKey] = map[string]string{"version": _esECSVersion}
+
+	return marshalJSON(doc)
+}
+
+// formatIndexName replaces every `{layout}` in pattern with t formatted in UTC.
+func formatIndexName(pattern string, t time.Time) string {
+	if !strings.Contains(pattern, "{") {
+		return pattern
+	}
+
+	t = t.UTC()
+	var sb strings.Builder
+	for {
+		start := strings.IndexByte(pattern, '{')
+		end := strings.IndexByte(pattern, '}')
+		if start < 0 || end < start {
+			sb.WriteString(pattern)
+			break
+		}
+		sb.WriteString(pattern[:start])
+		sb.WriteString(t.Format(pattern[start+1 : end]))
+		pattern = pattern[end+1:]
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,195 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBulk records documents of every `_bulk` request, and responds with
+// statuses decided by respond.
+type fakeBulk struct {
+	mu       sync.Mutex
+	requests [][]map[string]interface{} // documents of every request
+	indices  [][]string                 // index names of every request
+	auth     string
+
+	// respond returns the status of the nth document in the current request.
+	respond func(req, n int, doc map[string]interface{}) int
+}
+
+func (f *fakeBulk) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.auth = r.Header.Get("Authorization")
+	var docs []map[string]interface{}
+	var indices []string
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var action map[string]map[string]string
+		if err := json.Unmarshal(scanner.Bytes(), &action); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		indices = append(indices, action["index"]["_index"])
+
+		scanner.Scan()
+		var doc map[string]interface{}
+		_ = json.Unmarshal(scanner.Bytes(), &doc)
+		docs = append(docs, doc)
+	}
+
+	reqIdx := len(f.requests)
+	f.requests = append(f.requests, docs)
+	f.indices = append(f.indices, indices)
+
+	hasErrors := false
+	items := make([]string, 0, len(docs))
+	for i, doc := range docs {
+		status := http.StatusCreated
+		if f.respond != nil {
+			status = f.respond(reqIdx, i, doc)
+		}
+		item := fmt.Sprintf(`{"index":{"status":%d}}`, status)
+		if status >= 300 {
+			hasErrors = true
+			item = fmt.Sprintf(`{"index":{"status":%d,"error":{"type":"some_exception","reason":"doc %d"}}}`, status, i)
+		}
+		items = append(items, item)
+	}
+	_, _ = fmt.Fprintf(w, `{"took":1,"errors":%v,"items":[%s]}`, hasErrors, strings.Join(items, ","))
+}
+
+func (f *fakeBulk) result() ([][]map[string]interface{}, [][]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests, f.indices
+}
+
+func Test_ElasticsearchSink(t *testing.T) {
+	fake := &fakeBulk{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	sink, err := NewElasticsearchSink(srv.URL,
+		WithElasticsearchIndex("app-{2006.01.02}"),
+		WithElasticsearchBasicAuth("elastic", "changeme"),
+		WithElasticsearchBatch(10, time.Hour),
+	)
+	require.NoError(t, err)
+
+	l, err := NewLogger(
+		WithCustomWriter(ioutil.Discard),
+		WithReportCaller(true),
+		WithGlobalFields(Fields{"service": "gateway"}),
+		WithSinks(sink),
+	)
+	require.NoError(t, err)
+
+	l.WithFields(Fields{"message": "could not override", "cost": 12}).Warn("slow query")
+	require.NoError(t, sink.Close())
+
+	requests, indices := fake.result()
+	require.Len(t, requests, 1)
+	require.Len(t, requests[0], 1)
+	assert.Equal(t, "app-"+time.Now().UTC().Format("2006.01.02"), indices[0][0])
+	assert.Equal(t, "Basic ZWxhc3RpYzpjaGFuZ2VtZQ==", fake.auth)
+
+	doc := requests[0][0]
+	assert.Equal(t, "slow query", doc["message"])
+	assert.Equal(t, "gateway", doc["service"])
+	assert.Equal(t, float64(12), doc["cost"])
+	assert.NotEmpty(t, doc["@timestamp"])
+
+	logField := doc["log"].(map[string]interface{})
+	assert.Equal(t, "warning", logField["level"])
+	// callers inside this package could not be reported, see caller_test.go.
+	assert.Contains(t, logField, "origin")
+}
+
+func Test_ElasticsearchSink_PartialFailure(t *testing.T) {
+	fake := &fakeBulk{
+		respond: func(req, n int, doc map[string]interface{}) int {
+			if req != 0 {
+				return http.StatusCreated
+			}
+			switch doc["message"] {
+			case "throttled":
+				return http.StatusTooManyRequests
+			case "bad mapping":
+				return http.StatusBadRequest
+			}
+			return http.StatusCreated
+		},
+	}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	sink, err := NewElasticsearchSink(srv.URL,
+		WithElasticsearchBatch(10, time.Hour),
+		WithElasticsearchRetry(3, time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	var kinds []ErrorKind
+	l, err := NewLogger(WithCustomWriter(&bytes.Buffer{}), WithSinks(sink), WithErrorHandler(func(kind ErrorKind, err error) {
+		kinds = append(kinds, kind)
+	}))
+	require.NoError(t, err)
+
+	for _, msg := range []string{"ok", "throttled", "bad mapping"} {
+		l.Error(msg)
+	}
+	require.NoError(t, l.Close())
+	// the rejected document is reported to the ErrorHandler.
+	assert.Equal(t, []ErrorKind{ErrorKindSink}, kinds)
+
+	requests, _ := fake.result()
+	require.Len(t, requests, 2)
+	assert.Len(t, requests[0], 3)
+	// only the retryable failed document should be resent.
+	require.Len(t, requests[1], 1)
+	assert.Equal(t, "throttled", requests[1][0]["message"])
+}
+
+func Test_formatIndexName(t *testing.T) {
+	ts := time.Date(2026, 10, 17, 23, 0, 0, 0, time.FixedZone("UTC-8", -8*3600))
+
+	assert.Equal(t, "logs-2026.10.18", formatIndexName("logs-{2006.01.02}", ts))
+	assert.Equal(t, "logs", formatIndexName("logs", ts))
+	assert.Equal(t, "logs-2026-10.18", formatIndexName("logs-{2006}-{01.02}", ts))
+}
+
+func Test_encodeECSDocument(t *testing.T) {
+	e := &Entry{
+		Level:   LevelInfo,
+		Message: "hello",
+		Time:    time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC),
+		Fields:  Fields{"fn": func() {}, "err": fmt.Errorf("oops")},
+		Caller:  &runtime.Frame{File: "main.go", Line: 10, Function: "main.main"},
+	}
+
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(encodeECSDocument(e), &doc))
+	assert.Equal(t, "2026-10-17T00:00:00Z", doc["@timestamp"])
+	assert.Equal(t, "oops", doc["err"])
+	assert.True(t, bytes.HasPrefix([]byte(doc["fn"].(string)), []byte("0x")))
+	assert.Equal(t, map[string]interface{}{"version": _esECSVersion}, doc["ecs"])
+
+	origin := doc["log"].(map[string]interface{})["origin"].(map[string]interface{})
+	assert.Equal(t, "main.main", origin["function"])
+	assert.Equal(t, map[string]interface{}{"name": "main.go", "line": float64(10)}, origin["file"])
+}
@@ -0,0 +1,57 @@
+package log
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// FilterSink emits the entries those match the Filter into next, so that
+// every sink could receive its own part of entries, such as only the Error
+// entries of database into an alerting sink.
+type FilterSink struct {
+	filter *Filter
+	next   Sink
+}
+
+var _ Sink = &FilterSink{}
+
+// NewFilterSink creates a FilterSink wraps next, expr is parsed by ParseFilter.
+func NewFilterSink(next Sink, expr string) (*FilterSink, error) {
+	if next == nil {
+		return nil, errors.New("NewFilterSink: nil sink")
+	}
+	filter, err := ParseFilter(expr)
+	if err != nil {
+		return nil, errors.Wrap(err, "NewFilterSink")
+	}
+
+	return &FilterSink{filter: filter, next: next}, nil
+}
+
+// Emit emits e into next if it matches the filter.
+func (s *FilterSink) Emit(e *Entry) error {
+	if !s.filter.Match(e) {
+		return nil
+	}
+
+	return s.next.Emit(e)
+}
+
+// Sync syncs next.
+func (s *FilterSink) Sync() error {
+	if sy, ok := s.next.(syncer); ok {
+		return sy.Sync()
+	}
+
+	return nil
+}
+
+// Close closes next.
+func (s *FilterSink) Close() error {
+	if c, ok := s.next.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_FilterSink(t *testing.T) {
+	db, all := &syncSink{}, &syncSink{}
+	sink, err := NewFilterSink(db, `fields.component == "db" && level >= error`)
+	require.NoError(t, err)
+
+	l, err := NewLogger(WithCustomWriter(&bytes.Buffer{}), WithSinks(sink, all))
+	require.NoError(t, err)
+
+	l.WithFields(Fields{"component": "db"}).Error("to db")
+	l.WithFields(Fields{"component": "db"}).Info("info")
+	l.WithFields(Fields{"component": "http"}).Error("http")
+	require.NoError(t, l.Close())
+
+	assert.Equal(t, []string{"to db"}, db.messages())
+	assert.Equal(t, []string{"to db", "info", "http"}, all.messages())
+
+	_, err = NewFilterSink(db, `level >=`)
+	assert.Error(t, err)
+	_, err = NewFilterSink(nil, `level >= warn`)
+	assert.Error(t, err)
+}
@@ -0,0 +1,352 @@
+package log
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	_fluentDefaultTag     = "log"
+	_fluentDefaultTimeout = 3 * time.Second
+	_fluentLevelKey       = "level"
+	_fluentMessageKey     = "message"
+	_fluentCallerKey      = "caller"
+	_fluentFunctionKey    = "func"
+	_fluentEventTimeType  = 0
+)
+
+// FluentMode is the carrier mode of forward protocol.
+type FluentMode uint8
+
+const (
+	// FluentForward sends entries as array: [tag, [[time, record], ...], option].
+	FluentForward FluentMode = iota
+	// FluentPackedForward sends entries as msgpack stream in bin:
+	// [tag, bin([time, record][time, record]...), option].
+	FluentPackedForward
+)
+
+// FluentOption to apply single function into `fo`.
+type FluentOption func(fo *fluentOptions) error
+
+type fluentOptions struct {
+	tag        string // fixed tag
+	tagField   string // tag is derived from the field, fallback to tag if absent.
+	mode       FluentMode
+	ack        bool
+	ackTimeout time.Duration
+	timeout    time.Duration // dial and write timeout
+
+	batchSize int
+	batchWait time.Duration
+	retry     retryPolicy
+}
+
+// WithFluentTag sets the fixed tag of entries, default is `log`.
+func WithFluentTag(tag string) FluentOption {
+	return func(fo *fluentOptions) error {
+		if tag == "" {
+			return errors.New("WithFluentTag: empty tag")
+		}
+		fo.tag = tag
+		return nil
+	}
+}
+
+// WithFluentTagField derives the tag from the field value of key, entries
+// without the field would use the fixed tag.
+func WithFluentTagField(key string) FluentOption {
+	return func(fo *fluentOptions) error {
+		fo.tagField = key
+		return nil
+	}
+}
+
+// WithFluentMode sets the carrier mode, FluentForward is the default.
+func WithFluentMode(mode FluentMode) FluentOption {
+	return func(fo *fluentOptions) error {
+		if mode != FluentForward && mode != FluentPackedForward {
+			return errors.Errorf("WithFluentMode: unknown mode %d", mode)
+		}
+		fo.mode = mode
+		return nil
+	}
+}
+
+// WithFluentAck requires the server to acknowledge every chunk by the `chunk`
+// option, the chunk would be resent if the ack is not received in timeout.
+func WithFluentAck(timeout time.Duration) FluentOption {
+	return func(fo *fluentOptions) error {
+		fo.ack = true
+		fo.ackTimeout = timeout
+		if fo.ackTimeout <= 0 {
+			fo.ackTimeout = _fluentDefaultTimeout
+		}
+		return nil
+	}
+}
+
+// WithFluentTimeout sets the dial and write timeout.
+func WithFluentTimeout(timeout time.Duration) FluentOption {
+	return func(fo *fluentOptions) error {
+		fo.timeout = timeout
+		return nil
+	}
+}
+
+// WithFluentBatch sets max entries count in one chunk and the max duration
+// an entry would wait before being sent.
+func WithFluentBatch(size int, wait time.Duration) FluentOption {
+	return func(fo *fluentOptions) error {
+		fo.batchSize = size
+		fo.batchWait = wait
+		return nil
+	}
+}
+
+// WithFluentRetry sets max retry times and the first backoff duration, the
+// connection would be re-established before retrying.
+func WithFluentRetry(max int, backoff time.Duration) FluentOption {
+	return func(fo *fluentOptions) error {
+		fo.retry.max = max
+		fo.retry.backoff = backoff
+		return nil
+	}
+}
+
+// FluentSink sends entries to Fluentd or Fluent Bit by forward protocol.
+//
+// https://github.com/fluent/fluentd/wiki/Forward-Protocol-Specification-v1
+type FluentSink struct {
+	opt     *fluentOptions
+	network string
+	addr    string
+	batcher *batcher
+
+	conn   net.Conn // only accessed in batcher goroutine.
+	reader *bufio.Reader
+
+	sinkReporter
+}
+
+var (
+	_ Sink         = &FluentSink{}
+	_ loggerBinder = &FluentSink{}
+)
+
+// NewFluentSink creates a FluentSink, addr could be `host:port`,
+// `tcp://host:port` or `unix:///path/to/socket`. The connection is
+// established lazily, and it would be re-established if broken.
+func NewFluentSink(addr string, opts ...FluentOption) (*FluentSink, error) {
+	network := "tcp"
+	switch {
+	case strings.HasPrefix(addr, "unix://"):
+		network, addr = "unix", strings.TrimPrefix(addr, "unix://")
+	case strings.HasPrefix(addr, "tcp://"):
+		addr = strings.TrimPrefix(addr, "tcp://")
+	}
+	if addr == "" {
+		return nil, errors.New("NewFluentSink: empty addr")
+	}
+
+	fo := &fluentOptions{
+		tag:     _fluentDefaultTag,
+		mode:    FluentForward,
+		timeout: _fluentDefaultTimeout,
+		retry:   defaultRetryPolicy(),
+	}
+	for _, opt := range opts {
+		if err := opt(fo); err != nil {
+			return nil, errors.Wrap(err, "failed to apply option")
+		}
+	}
+
+	s := &FluentSink{
+		opt:     fo,
+		network: network,
+		addr:    addr,
+	}
+	s.batcher = newBatcher(fo.batchSize, fo.batchWait, s.send)
+
+	return s, nil
+}
+
+// fluentItem is an entry which has been encoded as [time, record].
+type fluentItem struct {
+	tag   string
+	event []byte
+}
+
+// Emit encodes e as [time, record] and queues it to be sent.
+func (s *FluentSink) Emit(e *Entry) error {
+	tag := s.opt.tag
+	if s.opt.tagField != "" {
+		if v, ok := e.Fields[s.opt.tagField]; ok {
+			tag = fmt.Sprintf(_interfaceFormat, v)
+		}
+	}
+
+	record := make(map[string]interface{}, len(e.Fields)+4)
+	for k, v := range e.Fields {
+		record[k] = v
+	}
+	record[_fluentLevelKey] = e.Level.name()
+	record[_fluentMessageKey] = e.Message
+	if e.Caller != nil {
+		record[_fluentCallerKey] = e.Caller.File + ":" + strconv.Itoa(e.Caller.Line)
+		record[_fluentFunctionKey] = e.Caller.Function
+	}
+
+	event := appendMsgpackArrayHeader(make([]byte, 0, 256), 2)
+	event = appendFluentEventTime(event, e.Time)
+	event = appendMsgpackMap(event, record)
+
+	return s.batcher.add(fluentItem{tag: tag, event: event})
+}
+
+// Sync sends the pending entries immediately.
+func (s *FluentSink) Sync() error {
+	s.batcher.sync()
+	return nil
+}
+
+// Close stops accepting entries, sends the pending entries
+// and closes the connection.
+func (s *FluentSink) Close() error {
+	s.batcher.close()
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+
+	return nil
+}
+
+// send sends items grouped by tag, one chunk per tag.
+func (s *FluentSink) send(items []interface{}) {
+	tags := make([]string, 0, 2)
+	events := make(map[string][][]byte, 2)
+	for _, v := range items {
+		item := v.(fluentItem)
+		if _, ok := events[item.tag]; !ok {
+			tags = append(tags, item.tag)
+		}
+		events[item.tag] = append(events[item.tag], item.event)
+	}
+
+	for _, tag := range tags {
+		chunk := s.encodeChunk(tag, events[tag])
+		err := s.opt.retry.do(func() error {
+			return s.write(chunk)
+		})
+		if err != nil {
+			s.report(errors.Wrapf(err, "forward %d entries to fluent", len(events[tag])))
+		}
+	}
+}
+
+// fluentChunk is a message which is ready to be written.
+type fluentChunk struct {
+	id   string // chunk id, empty if ack is not required.
+	data []byte
+}
+
+func (s *FluentSink) encodeChunk(tag string, events [][]byte) fluentChunk {
+	var chunk fluentChunk
+
+	b := appendMsgpackArrayHeader(nil, 3)
+	b = appendMsgpackString(b, tag)
+	switch s.opt.mode {
+	case FluentPackedForward:
+		size := 0
+		for _, event := range events {
+			size += len(event)
+		}
+		packed := make([]byte, 0, size)
+		for _, event := range events {
+			packed = append(packed, event...)
+		}
+		b = appendMsgpackBin(b, packed)
+	default:
+		b = appendMsgpackArrayHeader(b, len(events))
+		for _, event := range events {
+			b = append(b, event...)
+		}
+	}
+
+	option := map[string]interface{}{"size": len(events)}
+	if s.opt.ack {
+		chunk.id = newFluentChunkID()
+		option["chunk"] = chunk.id
+	}
+	chunk.data = appendMsgpackMap(b, option)
+
+	return chunk
+}
+
+// write writes chunk into connection and waits for the ack if required,
+// the connection would be closed if any error occurs, so that it could be
+// re-established in the next try.
+func (s *FluentSink) write(chunk fluentChunk) (err error) {
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.network, s.addr, s.opt.timeout)
+		if err != nil {
+			return errors.Wrap(err, "FluentSink.Dial")
+		}
+		s.conn = conn
+		s.reader = bufio.NewReader(conn)
+	}
+	defer func() {
+		if err != nil {
+			_ = s.conn.Close()
+			s.conn = nil
+			s.reader = nil
+		}
+	}()
+
+	if s.opt.timeout > 0 {
+		_ = s.conn.SetWriteDeadline(time.Now().Add(s.opt.timeout))
+	}
+	if _, err = s.conn.Write(chunk.data); err != nil {
+		return errors.Wrap(err, "FluentSink.Write")
+	}
+	if chunk.id == "" {
+		return nil
+	}
+
+	_ = s.conn.SetReadDeadline(time.Now().Add(s.opt.ackTimeout))
+	resp, err := readMsgpack(s.reader)
+	if err != nil {
+		return errors.Wrap(err, "FluentSink.readAck")
+	}
+	if m, ok := resp.(map[string]interface{}); !ok || m["ack"] != chunk.id {
+		return errors.Errorf("FluentSink: unexpected ack %v, want %s", resp, chunk.id)
+	}
+
+	return nil
+}
+
+// appendFluentEventTime appends t as EventTime ext type:
+// seconds and nanoseconds in 32-bit big-endian unsigned integer.
+func appendFluentEventTime(b []byte, t time.Time) []byte {
+	var data [8]byte
+	binary.BigEndian.PutUint32(data[:4], uint32(t.Unix()))
+	binary.BigEndian.PutUint32(data[4:], uint32(t.Nanosecond()))
+
+	return appendMsgpackExt(b, _fluentEventTimeType, data[:])
+}
+
+func newFluentChunkID() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+
+	return base64.StdEncoding.EncodeToString(id[:])
+}
@@ -0,0 +1,188 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeFluent is an in-process forward protocol server which decodes messages.
+type fakeFluent struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	messages [][]interface{}
+	conns    int
+
+	// dropFirst closes the first connection without ack after reading a message.
+	dropFirst bool
+}
+
+func newFakeFluent(t *testing.T, dropFirst bool) *fakeFluent {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	f := &fakeFluent{ln: ln, dropFirst: dropFirst}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+
+	return f
+}
+
+func (f *fakeFluent) serve(conn net.Conn) {
+	defer conn.Close()
+
+	f.mu.Lock()
+	f.conns++
+	drop := f.dropFirst && f.conns == 1
+	f.mu.Unlock()
+
+	r := bufio.NewReader(conn)
+	for {
+		v, err := readMsgpack(r)
+		if err != nil {
+			return
+		}
+		if drop {
+			return
+		}
+		msg := v.([]interface{})
+
+		f.mu.Lock()
+		f.messages = append(f.messages, msg)
+		f.mu.Unlock()
+
+		option, _ := msg[2].(map[string]interface{})
+		if chunk, ok := option["chunk"]; ok {
+			_, _ = conn.Write(appendMsgpackMap(nil, map[string]interface{}{"ack": chunk}))
+		}
+	}
+}
+
+func (f *fakeFluent) result() ([][]interface{}, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.messages, f.conns
+}
+
+// decodeFluentEvents decodes events of a message in Forward or PackedForward mode.
+func decodeFluentEvents(t *testing.T, msg []interface{}) [][]interface{} {
+	var events []interface{}
+	switch entries := msg[1].(type) {
+	case []interface{}:
+		events = entries
+	case []byte:
+		r := bufio.NewReader(bytes.NewReader(entries))
+		for {
+			v, err := readMsgpack(r)
+			if err != nil {
+				break
+			}
+			events = append(events, v)
+		}
+	default:
+		t.Fatalf("unexpected entries type: %T", msg[1])
+	}
+
+	out := make([][]interface{}, 0, len(events))
+	for _, v := range events {
+		out = append(out, v.([]interface{}))
+	}
+	return out
+}
+
+func Test_FluentSink_Forward(t *testing.T) {
+	fake := newFakeFluent(t, false)
+	defer fake.ln.Close()
+
+	sink, err := NewFluentSink("tcp://"+fake.ln.Addr().String(),
+		WithFluentTag("app.default"),
+		WithFluentTagField("component"),
+		WithFluentAck(time.Second),
+		WithFluentBatch(10, time.Hour),
+	)
+	require.NoError(t, err)
+
+	ts := time.Unix(1760000000, 5)
+	require.NoError(t, sink.Emit(&Entry{Level: LevelInfo, Message: "a", Time: ts, Fields: Fields{"component": "db", "cost": 1}}))
+	require.NoError(t, sink.Emit(&Entry{Level: LevelError, Message: "b", Time: ts}))
+	require.NoError(t, sink.Emit(&Entry{Level: LevelInfo, Message: "c", Time: ts, Fields: Fields{"component": "db"}}))
+	require.NoError(t, sink.Close())
+
+	messages, _ := fake.result()
+	require.Len(t, messages, 2)
+
+	assert.Equal(t, "db", messages[0][0])
+	events := decodeFluentEvents(t, messages[0])
+	require.Len(t, events, 2)
+	ext := events[0][0].(msgpackExt)
+	assert.Equal(t, int8(_fluentEventTimeType), ext.Type)
+	assert.Equal(t, uint32(1760000000), binary.BigEndian.Uint32(ext.Data[:4]))
+	assert.Equal(t, uint32(5), binary.BigEndian.Uint32(ext.Data[4:]))
+	assert.Equal(t, map[string]interface{}{
+		"component": "db", "cost": int64(1), "level": "info", "message": "a",
+	}, events[0][1])
+
+	assert.Equal(t, "app.default", messages[1][0])
+	option := messages[1][2].(map[string]interface{})
+	assert.Equal(t, int64(1), option["size"])
+	assert.NotEmpty(t, option["chunk"])
+}
+
+func Test_FluentSink_PackedForward_Reconnect(t *testing.T) {
+	fake := newFakeFluent(t, true)
+	defer fake.ln.Close()
+
+	sink, err := NewFluentSink(fake.ln.Addr().String(),
+		WithFluentMode(FluentPackedForward),
+		WithFluentAck(time.Second),
+		WithFluentBatch(10, time.Hour),
+		WithFluentRetry(3, time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	for _, msg := range []string{"a", "b", "c"} {
+		require.NoError(t, sink.Emit(&Entry{Level: LevelInfo, Message: msg, Time: time.Now()}))
+	}
+	require.NoError(t, sink.Close())
+
+	messages, conns := fake.result()
+	assert.Equal(t, 2, conns)
+	require.Len(t, messages, 1)
+	events := decodeFluentEvents(t, messages[0])
+	require.Len(t, events, 3)
+	assert.Equal(t, "c", events[2][1].(map[string]interface{})["message"])
+}
+
+func Test_FluentSink_errors(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	sink, err := NewFluentSink(addr,
+		WithFluentBatch(10, time.Hour),
+		WithFluentRetry(0, time.Millisecond),
+	)
+	require.NoError(t, err)
+	l, err := NewLogger(WithCustomWriter(&bytes.Buffer{}), WithSinks(sink), WithErrorHandler(nil))
+	require.NoError(t, err)
+
+	l.Info("unreachable")
+	require.NoError(t, l.Close())
+	assert.Equal(t, uint64(1), l.ErrorStats().SinkErrors, "reported to the ErrorHandler")
+}
@@ -0,0 +1,161 @@
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	_journaldSocket       = "/run/systemd/journal/socket"
+	_journaldMaxFieldName = 64
+)
+
+// JournaldOption to apply single function into `jo`.
+type JournaldOption func(jo *journaldOptions) error
+
+type journaldOptions struct {
+	socket     string // path of journald socket
+	identifier string // SYSLOG_IDENTIFIER
+}
+
+// WithJournaldSocket sets the path of journald native socket, it's useful
+// when journald listens on a non-default path or in test.
+func WithJournaldSocket(path string) JournaldOption {
+	return func(jo *journaldOptions) error {
+		if path == "" {
+			return errors.New("WithJournaldSocket: empty path")
+		}
+		jo.socket = path
+		return nil
+	}
+}
+
+// WithJournaldIdentifier sets SYSLOG_IDENTIFIER, default is the program name.
+func WithJournaldIdentifier(identifier string) JournaldOption {
+	return func(jo *journaldOptions) error {
+		jo.identifier = identifier
+		return nil
+	}
+}
+
+func newJournaldOptions(opts ...JournaldOption) (*journaldOptions, error) {
+	jo := &journaldOptions{
+		socket:     _journaldSocket,
+		identifier: filepath.Base(os.Args[0]),
+	}
+	for _, opt := range opts {
+		if err := opt(jo); err != nil {
+			return nil, errors.Wrap(err, "failed to apply option")
+		}
+	}
+
+	return jo, nil
+}
+
+// journaldPriority maps level to syslog priority.
+func journaldPriority(lv Level) int {
+	switch lv {
+	case LevelFatal:
+		return 2 // crit
+	case LevelError:
+		return 3 // err
+	case LevelWarning:
+		return 4 // warning
+	case LevelInfo:
+		return 6 // info
+	}
+
+	return 7 // debug
+}
+
+// journaldReserved are fields set by the sink, user fields with the same
+// names would be ignored.
+var journaldReserved = map[string]struct{}{
+	"MESSAGE":           {},
+	"PRIORITY":          {},
+	"CODE_FILE":         {},
+	"CODE_LINE":         {},
+	"CODE_FUNC":         {},
+	"SYSLOG_IDENTIFIER": {},
+}
+
+// encodeJournaldEntry encodes e in journald native protocol, every field is
+// `KEY=value\n`, or `KEY\n<64-bit little-endian size>value\n` if value
+// contains newline.
+//
+// https://systemd.io/JOURNAL_NATIVE_PROTOCOL/
+func encodeJournaldEntry(e *Entry, identifier string) []byte {
+	b := bytes.NewBuffer(make([]byte, 0, 256))
+	appendJournaldField(b, "MESSAGE", e.Message)
+	appendJournaldField(b, "PRIORITY", strconv.Itoa(journaldPriority(e.Level)))
+	if identifier != "" {
+		appendJournaldField(b, "SYSLOG_IDENTIFIER", identifier)
+	}
+	if e.Caller != nil {
+		appendJournaldField(b, "CODE_FILE", e.Caller.File)
+		appendJournaldField(b, "CODE_LINE", strconv.Itoa(e.Caller.Line))
+		appendJournaldField(b, "CODE_FUNC", e.Caller.Function)
+	}
+
+	for k, v := range e.Fields {
+		name := journaldFieldName(k)
+		if _, ok := journaldReserved[name]; ok {
+			continue
+		}
+
+		value, ok := v.(string)
+		if !ok {
+			value = fmt.Sprintf(_interfaceFormat, v)
+		}
+		appendJournaldField(b, name, value)
+	}
+
+	return b.Bytes()
+}
+
+func appendJournaldField(b *bytes.Buffer, name, value string) {
+	b.WriteString(name)
+	if !strings.ContainsRune(value, '\n') {
+		b.WriteByte('=')
+		b.WriteString(value)
+		b.WriteByte('\n')
+		return
+	}
+
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	b.WriteByte('\n')
+	b.Write(size[:])
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+// journaldFieldName converts key into a valid journal field name: upper-cased,
+// only letters, digits and underscores, not starting with underscore or digit
+// (fields start with underscore are trusted fields set by journald).
+func journaldFieldName(key string) string {
+	b := []byte(strings.ToUpper(strings.TrimLeft(key, "_")))
+	for i, c := range b {
+		if c == '_' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+			continue
+		}
+		b[i] = '_'
+	}
+
+	name := string(b)
+	if name == "" || '0' <= name[0] && name[0] <= '9' {
+		name = "FIELD_" + name
+	}
+	if len(name) > _journaldMaxFieldName {
+		name = name[:_journaldMaxFieldName]
+	}
+
+	return name
+}
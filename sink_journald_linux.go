@@ -0,0 +1,152 @@
+//go:build linux
+// +build linux
+
+package log
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	_memfdCloexec       = 0x1
+	_memfdAllowSealing  = 0x2
+	_fcntlAddSeals      = 1033
+	_sealAll            = 0x1 | 0x2 | 0x4 | 0x8 // SEAL, SHRINK, GROW, WRITE
+	_journaldShmDir     = "/dev/shm"
+	_journaldShmPattern = "journal-"
+)
+
+// JournaldSink writes entries to systemd-journald by native protocol.
+// Level is mapped to PRIORITY, caller is written as CODE_FILE, CODE_LINE
+// and CODE_FUNC, and every field becomes an upper-cased journal field.
+type JournaldSink struct {
+	opt  *journaldOptions
+	addr *net.UnixAddr
+	conn *net.UnixConn
+}
+
+var _ Sink = &JournaldSink{}
+
+// NewJournaldSink creates a JournaldSink, it fails if journald socket does
+// not exist, which means the process is not running under systemd.
+func NewJournaldSink(opts ...JournaldOption) (*JournaldSink, error) {
+	jo, err := newJournaldOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = os.Stat(jo.socket); err != nil {
+		return nil, errors.Wrapf(err, "NewJournaldSink.Stat socket: %s", jo.socket)
+	}
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, errors.Wrap(err, "NewJournaldSink.ListenUnixgram")
+	}
+
+	return &JournaldSink{
+		opt:  jo,
+		addr: &net.UnixAddr{Name: jo.socket, Net: "unixgram"},
+		conn: conn,
+	}, nil
+}
+
+// Emit writes e into journald in one datagram. If the datagram is too large,
+// the payload would be written into a sealed memfd (or an unlinked file in
+// /dev/shm if memfd is not available), and the fd is passed instead.
+func (s *JournaldSink) Emit(e *Entry) error {
+	data := encodeJournaldEntry(e, s.opt.identifier)
+
+	_, _, err := s.conn.WriteMsgUnix(data, nil, s.addr)
+	if err == nil {
+		return nil
+	}
+	if !isMessageTooLarge(err) {
+		return errors.Wrap(err, "JournaldSink.WriteMsgUnix")
+	}
+
+	return s.emitByFd(data)
+}
+
+// Close closes the socket.
+func (s *JournaldSink) Close() error {
+	return s.conn.Close()
+}
+
+func (s *JournaldSink) emitByFd(data []byte) error {
+	f, err := createJournaldPayloadFile(data)
+	if err != nil {
+		return errors.Wrap(err, "JournaldSink.createPayloadFile")
+	}
+	defer f.Close()
+
+	rights := syscall.UnixRights(int(f.Fd()))
+	if _, _, err = s.conn.WriteMsgUnix(nil, rights, s.addr); err != nil {
+		return errors.Wrap(err, "JournaldSink.WriteMsgUnix fd")
+	}
+
+	return nil
+}
+
+// createJournaldPayloadFile writes data into a sealed memfd, journald only
+// accepts sealed memfd. If memfd is not supported, an unlinked regular file
+// in /dev/shm is used instead, just like sd_journal_sendv does.
+func createJournaldPayloadFile(data []byte) (*os.File, error) {
+	if _sysMemfdCreate > 0 {
+		f, err := createSealedMemfd(data)
+		if err == nil {
+			return f, nil
+		}
+	}
+
+	f, err := ioutil.TempFile(_journaldShmDir, _journaldShmPattern)
+	if err != nil {
+		return nil, err
+	}
+	_ = os.Remove(f.Name())
+	if _, err = f.Write(data); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func createSealedMemfd(data []byte) (*os.File, error) {
+	name, err := syscall.BytePtrFromString("journal-payload")
+	if err != nil {
+		return nil, err
+	}
+
+	fd, _, errno := syscall.Syscall(_sysMemfdCreate, uintptr(unsafe.Pointer(name)), _memfdCloexec|_memfdAllowSealing, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+
+	f := os.NewFile(fd, "journal-payload")
+	if _, err = f.Write(data); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if _, _, errno = syscall.Syscall(syscall.SYS_FCNTL, fd, _fcntlAddSeals, _sealAll); errno != 0 {
+		_ = f.Close()
+		return nil, errno
+	}
+
+	return f, nil
+}
+
+// isMessageTooLarge reports whether err means the datagram is too large.
+func isMessageTooLarge(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+
+	return errno == syscall.EMSGSIZE || errno == syscall.ENOBUFS
+}
@@ -0,0 +1,4 @@
+package log
+
+// _sysMemfdCreate is not defined by syscall package on amd64.
+const _sysMemfdCreate = 319
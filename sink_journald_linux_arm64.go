@@ -0,0 +1,5 @@
+package log
+
+import "syscall"
+
+const _sysMemfdCreate = syscall.SYS_MEMFD_CREATE
@@ -0,0 +1,7 @@
+//go:build linux && !amd64 && !arm64
+// +build linux,!amd64,!arm64
+
+package log
+
+// _sysMemfdCreate is unknown, the unlinked file in /dev/shm would be used.
+const _sysMemfdCreate = 0
@@ -0,0 +1,126 @@
+//go:build linux
+// +build linux
+
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeJournald listens on a unixgram socket like journald, payloads passed
+// by fd are read out from the file.
+func fakeJournald(t *testing.T) (string, <-chan []byte, func()) {
+	dir, err := ioutil.TempDir("", "journald")
+	require.NoError(t, err)
+	path := filepath.Join(dir, "socket")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+
+	ch := make(chan []byte, 4)
+	go func() {
+		buf := make([]byte, 1<<16)
+		oob := make([]byte, 1024)
+		for {
+			n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+			if err != nil {
+				close(ch)
+				return
+			}
+			if oobn == 0 {
+				ch <- append([]byte(nil), buf[:n]...)
+				continue
+			}
+
+			msgs, _ := syscall.ParseSocketControlMessage(oob[:oobn])
+			fds, _ := syscall.ParseUnixRights(&msgs[0])
+			f := os.NewFile(uintptr(fds[0]), "payload")
+			_, _ = f.Seek(0, 0)
+			data, _ := ioutil.ReadAll(f)
+			_ = f.Close()
+			ch <- data
+		}
+	}()
+
+	return path, ch, func() {
+		_ = conn.Close()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func Test_JournaldSink(t *testing.T) {
+	path, ch, cleanup := fakeJournald(t)
+	defer cleanup()
+
+	sink, err := NewJournaldSink(WithJournaldSocket(path), WithJournaldIdentifier("test"))
+	require.NoError(t, err)
+	defer sink.Close()
+
+	l, err := NewLogger(
+		WithCustomWriter(ioutil.Discard),
+		WithSinks(sink),
+	)
+	require.NoError(t, err)
+	l.WithField("request_id", "r1").Error("failed")
+
+	select {
+	case data := <-ch:
+		fields := parseJournaldEntry(t, data)
+		assert.Equal(t, []string{"failed"}, fields["MESSAGE"])
+		assert.Equal(t, []string{"3"}, fields["PRIORITY"])
+		assert.Equal(t, []string{"test"}, fields["SYSLOG_IDENTIFIER"])
+		assert.Equal(t, []string{"r1"}, fields["REQUEST_ID"])
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+}
+
+func Test_JournaldSink_LargePayload(t *testing.T) {
+	path, ch, cleanup := fakeJournald(t)
+	defer cleanup()
+
+	sink, err := NewJournaldSink(WithJournaldSocket(path))
+	require.NoError(t, err)
+	defer sink.Close()
+
+	// larger than the max datagram size, it must be passed by fd.
+	large := string(bytes.Repeat([]byte("x"), 4<<20))
+	require.NoError(t, sink.Emit(&Entry{Level: LevelInfo, Message: large, Time: time.Now()}))
+
+	select {
+	case data := <-ch:
+		fields := parseJournaldEntry(t, data)
+		assert.Equal(t, []string{large}, fields["MESSAGE"])
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+}
+
+func Test_NewJournaldSink_NoSocket(t *testing.T) {
+	_, err := NewJournaldSink(WithJournaldSocket("/path/not/exists"))
+	assert.Error(t, err)
+}
+
+func Test_createSealedMemfd(t *testing.T) {
+	if _sysMemfdCreate <= 0 {
+		t.Skip("memfd_create is unknown on this arch")
+	}
+
+	f, err := createSealedMemfd([]byte("payload"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	// sealed memfd could not be written any more.
+	_, err = f.Write([]byte("more"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,26 @@
+//go:build !linux
+// +build !linux
+
+package log
+
+import "github.com/pkg/errors"
+
+// JournaldSink is only supported on linux.
+type JournaldSink struct{}
+
+var _ Sink = &JournaldSink{}
+
+// NewJournaldSink always fails since journald is only available on linux.
+func NewJournaldSink(opts ...JournaldOption) (*JournaldSink, error) {
+	return nil, errors.New("NewJournaldSink: journald is only supported on linux")
+}
+
+// Emit does nothing.
+func (s *JournaldSink) Emit(e *Entry) error {
+	return nil
+}
+
+// Close does nothing.
+func (s *JournaldSink) Close() error {
+	return nil
+}
@@ -0,0 +1,68 @@
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseJournaldEntry parses journald native protocol payload.
+func parseJournaldEntry(t *testing.T, data []byte) map[string][]string {
+	fields := make(map[string][]string)
+	for len(data) > 0 {
+		nl := bytes.IndexByte(data, '\n')
+		require.True(t, nl > 0)
+		line := data[:nl]
+
+		if eq := bytes.IndexByte(line, '='); eq >= 0 {
+			fields[string(line[:eq])] = append(fields[string(line[:eq])], string(line[eq+1:]))
+			data = data[nl+1:]
+			continue
+		}
+
+		name := string(line)
+		data = data[nl+1:]
+		size := binary.LittleEndian.Uint64(data[:8])
+		fields[name] = append(fields[name], string(data[8:8+size]))
+		require.Equal(t, byte('\n'), data[8+size])
+		data = data[8+size+1:]
+	}
+	return fields
+}
+
+func Test_encodeJournaldEntry(t *testing.T) {
+	e := &Entry{
+		Level:   LevelWarning,
+		Message: "multi\nline",
+		Time:    time.Now(),
+		Fields:  Fields{"user_id": 10, "_context": "ctx", "trace.id": "abc", "message": "ignored"},
+		Caller:  &runtime.Frame{File: "main.go", Line: 12, Function: "main.main"},
+	}
+
+	fields := parseJournaldEntry(t, encodeJournaldEntry(e, "app"))
+	assert.Equal(t, map[string][]string{
+		"MESSAGE":           {"multi\nline"},
+		"PRIORITY":          {"4"},
+		"SYSLOG_IDENTIFIER": {"app"},
+		"CODE_FILE":         {"main.go"},
+		"CODE_LINE":         {"12"},
+		"CODE_FUNC":         {"main.main"},
+		"USER_ID":           {"10"},
+		"CONTEXT":           {"ctx"},
+		"TRACE_ID":          {"abc"},
+	}, fields)
+}
+
+func Test_journaldFieldName(t *testing.T) {
+	assert.Equal(t, "USER_ID", journaldFieldName("user_id"))
+	assert.Equal(t, "CONTEXT", journaldFieldName("__context"))
+	assert.Equal(t, "FIELD_1ST", journaldFieldName("1st"))
+	assert.Equal(t, "FIELD_", journaldFieldName("_"))
+	assert.Equal(t, "A_B_C", journaldFieldName("a-b.c"))
+	assert.Len(t, journaldFieldName(string(make([]byte, 100))), _journaldMaxFieldName)
+}
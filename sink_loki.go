@@ -0,0 +1,378 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	_lokiPushPath    = "/loki/api/v1/push"
+	_lokiTenantKey   = "X-Scope-OrgID"
+	_lokiLevelLabel  = "level"
+	_lokiMessageKey  = "msg"
+	_lokiCallerKey   = "caller"
+	_lokiFunctionKey = "func"
+)
+
+// LokiEncoding is the body encoding of push request.
+type LokiEncoding uint8
+
+const (
+	// LokiJSON push streams in JSON.
+	LokiJSON LokiEncoding = iota
+	// LokiProtobuf push streams in snappy compressed protobuf.
+	LokiProtobuf
+)
+
+// LokiOption to apply single function into `lo`.
+type LokiOption func(lo *lokiOptions) error
+
+type lokiOptions struct {
+	labels   []string // field keys those would be used as stream labels.
+	tenant   string   // X-Scope-OrgID header
+	encoding LokiEncoding
+	client   *http.Client
+
+	batchSize int
+	batchWait time.Duration
+	retry     retryPolicy
+}
+
+// WithLokiLabels sets the field keys those would be promoted to stream labels,
+// global fields are included in fields so they could be used too. Keep in mind
+// that labels should be low cardinality, `level` label is always attached.
+func WithLokiLabels(keys ...string) LokiOption {
+	return func(lo *lokiOptions) error {
+		lo.labels = append(lo.labels, keys...)
+		return nil
+	}
+}
+
+// WithLokiTenant sets X-Scope-OrgID header for multi-tenant Loki.
+func WithLokiTenant(tenant string) LokiOption {
+	return func(lo *lokiOptions) error {
+		lo.tenant = tenant
+		return nil
+	}
+}
+
+// WithLokiEncoding sets the body encoding, LokiJSON is the default.
+func WithLokiEncoding(enc LokiEncoding) LokiOption {
+	return func(lo *lokiOptions) error {
+		if enc != LokiJSON && enc != LokiProtobuf {
+			return errors.Errorf("WithLokiEncoding: unknown encoding %d", enc)
+		}
+		lo.encoding = enc
+		return nil
+	}
+}
+
+// WithLokiBatch sets max entries count in one push request and the max
+// duration an entry would wait before being pushed.
+func WithLokiBatch(size int, wait time.Duration) LokiOption {
+	return func(lo *lokiOptions) error {
+		lo.batchSize = size
+		lo.batchWait = wait
+		return nil
+	}
+}
+
+// WithLokiRetry sets max retry times and the first backoff duration of
+// failed push request, backoff would be doubled after every failure.
+func WithLokiRetry(max int, backoff time.Duration) LokiOption {
+	return func(lo *lokiOptions) error {
+		lo.retry.max = max
+		lo.retry.backoff = backoff
+		return nil
+	}
+}
+
+// WithLokiHTTPClient sets a custom http.Client to push.
+func WithLokiHTTPClient(client *http.Client) LokiOption {
+	return func(lo *lokiOptions) error {
+		if client == nil {
+			return errors.New("WithLokiHTTPClient: nil client")
+		}
+		lo.client = client
+		return nil
+	}
+}
+
+// LokiSink pushes entries to Grafana Loki push API in batch. Entries are grouped
+// into streams by labels, and the remaining fields are sent as JSON log line.
+type LokiSink struct {
+	opt     *lokiOptions
+	url     string
+	header  http.Header
+	batcher *batcher
+
+	sinkReporter
+}
+
+var (
+	_ Sink         = &LokiSink{}
+	_ loggerBinder = &LokiSink{}
+)
+
+// NewLokiSink creates a LokiSink pushes to addr, addr could be the base address
+// like `http://loki:3100` or the full push API URL.
+func NewLokiSink(addr string, opts ...LokiOption) (*LokiSink, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "NewLokiSink.Parse addr: %s", addr)
+	}
+	if !strings.HasSuffix(u.Path, _lokiPushPath) {
+		u.Path = strings.TrimSuffix(u.Path, "/") + _lokiPushPath
+	}
+
+	lo := &lokiOptions{
+		encoding: LokiJSON,
+		client:   &http.Client{Timeout: _defaultHTTPTimeout},
+		retry:    defaultRetryPolicy(),
+	}
+	for _, opt := range opts {
+		if err = opt(lo); err != nil {
+			return nil, errors.Wrap(err, "failed to apply option")
+		}
+	}
+
+	header := make(http.Header)
+	switch lo.encoding {
+	case LokiProtobuf:
+		header.Set("Content-Type", "application/x-protobuf")
+	default:
+		header.Set("Content-Type", "application/json")
+	}
+	if lo.tenant != "" {
+		header.Set(_lokiTenantKey, lo.tenant)
+	}
+
+	s := &LokiSink{
+		opt:    lo,
+		url:    u.String(),
+		header: header,
+	}
+	s.batcher = newBatcher(lo.batchSize, lo.batchWait, s.push)
+
+	return s, nil
+}
+
+// lokiLabel is a stream label pair.
+type lokiLabel struct {
+	name  string
+	value string
+}
+
+// lokiItem is an entry which has been converted into Loki's model.
+type lokiItem struct {
+	labels []lokiLabel // sorted by name
+	ts     time.Time
+	line   string
+}
+
+// Emit converts e into stream labels and log line, and queues it to be pushed.
+func (s *LokiSink) Emit(e *Entry) error {
+	item := lokiItem{
+		labels: make([]lokiLabel, 0, len(s.opt.labels)+1),
+		ts:     e.Time,
+	}
+	item.labels = append(item.labels, lokiLabel{name: _lokiLevelLabel, value: e.Level.name()})
+
+	line := make(map[string]interface{}, len(e.Fields)+3)
+	for k, v := range e.Fields {
+		line[k] = v
+	}
+	for _, key := range s.opt.labels {
+		v, ok := line[key]
+		if !ok {
+			continue
+		}
+		delete(line, key)
+		item.labels = append(item.labels, lokiLabel{
+			name:  sanitizeLokiLabelName(key),
+			value: fmt.Sprintf(_interfaceFormat, v),
+		})
+	}
+	sort.Slice(item.labels, func(i, j int) bool {
+		return item.labels[i].name < item.labels[j].name
+	})
+
+	line[_lokiMessageKey] = e.Message
+	if e.Caller != nil {
+		line[_lokiCallerKey] = e.Caller.File + ":" + strconv.Itoa(e.Caller.Line)
+		line[_lokiFunctionKey] = e.Caller.Function
+	}
+	item.line = string(marshalJSON(line))
+
+	return s.batcher.add(item)
+}
+
+// Sync pushes the pending entries immediately.
+func (s *LokiSink) Sync() error {
+	s.batcher.sync()
+	return nil
+}
+
+// Close stops accepting entries and pushes the pending entries.
+func (s *LokiSink) Close() error {
+	s.batcher.close()
+	return nil
+}
+
+// lokiStream is a group of items those have the same labels.
+type lokiStream struct {
+	labels []lokiLabel
+	items  []lokiItem
+}
+
+func (s *LokiSink) push(items []interface{}) {
+	streams := groupLokiStreams(items)
+
+	var body []byte
+	switch s.opt.encoding {
+	case LokiProtobuf:
+		body = snappyEncode(encodeLokiProtobuf(streams))
+	default:
+		body = encodeLokiJSON(streams)
+	}
+
+	err := s.opt.retry.do(func() error {
+		_, err := postHTTP(s.opt.client, s.url, s.header, body)
+		return err
+	})
+	if err != nil {
+		s.report(errors.Wrapf(err, "push %d entries to loki", len(items)))
+	}
+}
+
+// groupLokiStreams groups items into streams in order of their first appearance.
+func groupLokiStreams(items []interface{}) []*lokiStream {
+	streams := make([]*lokiStream, 0, 4)
+	index := make(map[string]*lokiStream, 4)
+
+	for _, v := range items {
+		item := v.(lokiItem)
+		key := formatLokiLabels(item.labels)
+		stream, ok := index[key]
+		if !ok {
+			stream = &lokiStream{labels: item.labels}
+			index[key] = stream
+			streams = append(streams, stream)
+		}
+		stream.items = append(stream.items, item)
+	}
+
+	return streams
+}
+
+// encodeLokiJSON encodes streams as:
+// {"streams":[{"stream":{"level":"info"},"values":[["<unix ns>","<line>"]]}]}
+func encodeLokiJSON(streams []*lokiStream) []byte {
+	type jsonStream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+	req := struct {
+		Streams []jsonStream `json:"streams"`
+	}{
+		Streams: make([]jsonStream, 0, len(streams)),
+	}
+
+	for _, stream := range streams {
+		js := jsonStream{
+			Stream: make(map[string]string, len(stream.labels)),
+			Values: make([][2]string, 0, len(stream.items)),
+		}
+		for _, label := range stream.labels {
+			js.Stream[label.name] = label.value
+		}
+		for _, item := range stream.items {
+			js.Values = append(js.Values, [2]string{strconv.FormatInt(item.ts.UnixNano(), 10), item.line})
+		}
+		req.Streams = append(req.Streams, js)
+	}
+
+	// only strings inside, it never fails.
+	data, _ := json.Marshal(req)
+	return data
+}
+
+// encodeLokiProtobuf encodes streams as logproto.PushRequest:
+//
+//	message PushRequest { repeated StreamAdapter streams = 1; }
+//	message StreamAdapter { string labels = 1; repeated EntryAdapter entries = 2; }
+//	message EntryAdapter { google.protobuf.Timestamp timestamp = 1; string line = 2; }
+func encodeLokiProtobuf(streams []*lokiStream) []byte {
+	var req []byte
+	for _, stream := range streams {
+		var sb []byte
+		sb = appendProtoBytes(sb, 1, []byte(formatLokiLabels(stream.labels)))
+		for _, item := range stream.items {
+			var ts []byte
+			if sec := item.ts.Unix(); sec != 0 {
+				ts = appendProtoVarint(ts, 1, uint64(sec))
+			}
+			if nsec := item.ts.Nanosecond(); nsec != 0 {
+				ts = appendProtoVarint(ts, 2, uint64(nsec))
+			}
+
+			var eb []byte
+			eb = appendProtoBytes(eb, 1, ts)
+			eb = appendProtoBytes(eb, 2, []byte(item.line))
+			sb = appendProtoBytes(sb, 2, eb)
+		}
+		req = appendProtoBytes(req, 1, sb)
+	}
+
+	return req
+}
+
+func appendProtoVarint(b []byte, field int, v uint64) []byte {
+	b = appendUvarint(b, uint64(field)<<3)
+	return appendUvarint(b, v)
+}
+
+func appendProtoBytes(b []byte, field int, v []byte) []byte {
+	b = appendUvarint(b, uint64(field)<<3|2)
+	b = appendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// formatLokiLabels formats labels in Prometheus style: {a="b", c="d"}.
+func formatLokiLabels(labels []lokiLabel) string {
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, label := range labels {
+		if i != 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(label.name)
+		sb.WriteByte('=')
+		sb.WriteString(strconv.Quote(label.value))
+	}
+	sb.WriteByte('}')
+
+	return sb.String()
+}
+
+// sanitizeLokiLabelName replaces the characters those are not allowed
+// in label name with '_', label name must match [a-zA-Z_][a-zA-Z0-9_]*.
+func sanitizeLokiLabelName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || i > 0 && '0' <= c && c <= '9' {
+			continue
+		}
+		b[i] = '_'
+	}
+
+	return string(b)
+}
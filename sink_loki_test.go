@@ -0,0 +1,205 @@
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLoki records push requests.
+type fakeLoki struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+
+	// fails the first n requests with status code.
+	failN    int32
+	failCode int
+}
+
+func (f *fakeLoki) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	if atomic.AddInt32(&f.failN, -1) >= 0 {
+		w.WriteHeader(f.failCode)
+		return
+	}
+
+	f.mu.Lock()
+	f.requests = append(f.requests, r)
+	f.bodies = append(f.bodies, body)
+	f.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (f *fakeLoki) result() ([]*http.Request, [][]byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests, f.bodies
+}
+
+func Test_LokiSink_JSON(t *testing.T) {
+	fake := &fakeLoki{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	sink, err := NewLokiSink(srv.URL,
+		WithLokiLabels("app", "component"),
+		WithLokiTenant("tenant-1"),
+		WithLokiBatch(10, time.Hour),
+	)
+	require.NoError(t, err)
+
+	l, err := NewLogger(
+		WithCustomWriter(ioutil.Discard),
+		WithGlobalFields(Fields{"app": "gateway"}),
+		WithSinks(sink),
+	)
+	require.NoError(t, err)
+
+	l.WithField("component", "db").Info("connected")
+	l.WithFields(Fields{"component": "db", "cost": 12}).Info("query")
+	l.WithField("user", "u1").Error("failed")
+	require.NoError(t, sink.Close())
+
+	requests, bodies := fake.result()
+	require.Len(t, requests, 1)
+	r := requests[0]
+	assert.Equal(t, _lokiPushPath, r.URL.Path)
+	assert.Equal(t, "tenant-1", r.Header.Get(_lokiTenantKey))
+	assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+	var req struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}
+	require.NoError(t, json.Unmarshal(bodies[0], &req))
+	require.Len(t, req.Streams, 2)
+
+	assert.Equal(t, map[string]string{"app": "gateway", "component": "db", "level": "info"}, req.Streams[0].Stream)
+	require.Len(t, req.Streams[0].Values, 2)
+	assert.JSONEq(t, `{"msg":"query","cost":12}`, req.Streams[0].Values[1][1])
+
+	assert.Equal(t, map[string]string{"app": "gateway", "level": "error"}, req.Streams[1].Stream)
+	assert.JSONEq(t, `{"msg":"failed","user":"u1"}`, req.Streams[1].Values[0][1])
+}
+
+func Test_LokiSink_Protobuf(t *testing.T) {
+	fake := &fakeLoki{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	sink, err := NewLokiSink(srv.URL+"/loki/api/v1/push",
+		WithLokiEncoding(LokiProtobuf),
+		WithLokiBatch(10, time.Hour),
+	)
+	require.NoError(t, err)
+
+	ts := time.Unix(1760000000, 123)
+	require.NoError(t, sink.Emit(&Entry{Level: LevelWarning, Message: "slow", Time: ts, Fields: Fields{"cost": 3}}))
+	require.NoError(t, sink.Close())
+
+	requests, bodies := fake.result()
+	require.Len(t, requests, 1)
+	assert.Equal(t, _lokiPushPath, requests[0].URL.Path)
+	assert.Equal(t, "application/x-protobuf", requests[0].Header.Get("Content-Type"))
+
+	raw, err := snappyDecode(bodies[0])
+	require.NoError(t, err)
+
+	streams := decodeProtoFields(t, raw)[1]
+	require.Len(t, streams, 1)
+	stream := decodeProtoFields(t, streams[0])
+	assert.Equal(t, `{level="warning"}`, string(stream[1][0]))
+	require.Len(t, stream[2], 1)
+
+	entry := decodeProtoFields(t, stream[2][0])
+	timestamp := decodeProtoFields(t, entry[1][0])
+	sec, _ := binary.Uvarint(timestamp[1][0])
+	nsec, _ := binary.Uvarint(timestamp[2][0])
+	assert.Equal(t, uint64(1760000000), sec)
+	assert.Equal(t, uint64(123), nsec)
+	assert.JSONEq(t, `{"msg":"slow","cost":3}`, string(entry[2][0]))
+}
+
+func Test_LokiSink_Retry(t *testing.T) {
+	fake := &fakeLoki{failN: 2, failCode: http.StatusServiceUnavailable}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	sink, err := NewLokiSink(srv.URL,
+		WithLokiBatch(10, time.Hour),
+		WithLokiRetry(3, time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, sink.Emit(&Entry{Level: LevelInfo, Message: "retry", Time: time.Now()}))
+	require.NoError(t, sink.Close())
+	requests, _ := fake.result()
+	assert.Len(t, requests, 1)
+
+	// client error should not be retried
+	fake = &fakeLoki{failN: 1, failCode: http.StatusBadRequest}
+	srv2 := httptest.NewServer(fake)
+	defer srv2.Close()
+
+	sink, err = NewLokiSink(srv2.URL,
+		WithLokiBatch(10, time.Hour),
+		WithLokiRetry(3, time.Millisecond),
+	)
+	require.NoError(t, err)
+	l, err := NewLogger(WithCustomWriter(&bytes.Buffer{}), WithSinks(sink), WithErrorHandler(nil))
+	require.NoError(t, err)
+	l.Info("bad")
+	require.NoError(t, l.Close())
+	requests, _ = fake.result()
+	assert.Len(t, requests, 0)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&fake.failN))
+	assert.Equal(t, uint64(1), l.ErrorStats().SinkErrors, "reported to the ErrorHandler")
+
+	assert.Equal(t, errSinkClosed, sink.Emit(&Entry{}))
+}
+
+// decodeProtoFields decodes length-delimited and varint fields of a protobuf
+// message, varint values are kept raw so they could be read by binary.Uvarint.
+func decodeProtoFields(t *testing.T, b []byte) map[int][][]byte {
+	fields := make(map[int][][]byte)
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		require.True(t, n > 0)
+		b = b[n:]
+
+		field := int(key >> 3)
+		switch key & 7 {
+		case 0:
+			_, n = binary.Uvarint(b)
+			fields[field] = append(fields[field], b[:n])
+			b = b[n:]
+		case 2:
+			length, n := binary.Uvarint(b)
+			b = b[n:]
+			fields[field] = append(fields[field], b[:length])
+			b = b[length:]
+		default:
+			t.Fatalf("unexpected wire type: %d", key&7)
+		}
+	}
+	return fields
+}
+
+func Test_sanitizeLokiLabelName(t *testing.T) {
+	assert.Equal(t, "service_name", sanitizeLokiLabelName("service.name"))
+	assert.Equal(t, "_abc", sanitizeLokiLabelName("1abc"))
+	assert.Equal(t, "a1", sanitizeLokiLabelName("a1"))
+}
@@ -0,0 +1,54 @@
+package log
+
+import (
+	"io"
+)
+
+// WriterSink writes entries into w in text format, it's used to apply sink
+// wrappers such as DedupSink on console or other writers.
+type WriterSink struct {
+	w         io.Writer
+	formatter Formatter
+}
+
+var _ Sink = &WriterSink{}
+
+// NewWriterSink creates a WriterSink writes into w, fields are sorted, and
+// colored if w is terminal. w would be locked if it's not safe for concurrent
+// use, see ConcurrentWriter.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{
+		w:         lockWriter(w),
+		formatter: newTextFormatter(isTerminal(w), true, false, ""),
+	}
+}
+
+// Emit formats e and writes it by one Write call.
+func (s *WriterSink) Emit(e *Entry) error {
+	data, err := formatEntry(s.formatter, e)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.w.Write(data)
+	return err
+}
+
+// Sync calls Sync of w if it's implemented, os.Stdout and os.Stderr are skipped.
+func (s *WriterSink) Sync() error {
+	if sy, ok := s.w.(syncer); ok && !isStdStream(s.w) {
+		return sy.Sync()
+	}
+
+	return nil
+}
+
+// Close calls Close of w if it's implemented, os.Stdout and os.Stderr are
+// never closed.
+func (s *WriterSink) Close() error {
+	if c, ok := s.w.(io.Closer); ok && !isStdStream(s.w) {
+		return c.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// snappyDecode decodes snappy block, only used to verify snappyEncode.
+func snappyDecode(src []byte) ([]byte, error) {
+	n, read := binary.Uvarint(src)
+	if read <= 0 {
+		return nil, errors.New("bad length")
+	}
+	src = src[read:]
+
+	dst := make([]byte, 0, n)
+	for len(src) > 0 {
+		tag := src[0]
+		switch tag & 3 {
+		case 0:
+			length := int(tag >> 2)
+			src = src[1:]
+			if length >= 60 {
+				extra := length - 59
+				length = 0
+				for i := 0; i < extra; i++ {
+					length |= int(src[i]) << (8 * i)
+				}
+				src = src[extra:]
+			}
+			length++
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+			continue
+		case 1:
+			length := int(tag>>2&7) + 4
+			offset := int(tag>>5)<<8 | int(src[1])
+			dst = snappyCopy(dst, offset, length)
+			src = src[2:]
+		case 2:
+			length := int(tag>>2) + 1
+			offset := int(binary.LittleEndian.Uint16(src[1:]))
+			dst = snappyCopy(dst, offset, length)
+			src = src[3:]
+		default:
+			return nil, errors.New("unexpected 4-byte offset copy")
+		}
+	}
+
+	if uint64(len(dst)) != n {
+		return nil, errors.New("length mismatch")
+	}
+	return dst, nil
+}
+
+func snappyCopy(dst []byte, offset, length int) []byte {
+	start := len(dst) - offset
+	for i := 0; i < length; i++ {
+		dst = append(dst, dst[start+i])
+	}
+	return dst
+}
+
+func Test_snappyEncode(t *testing.T) {
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	tests := []struct {
+		name string
+		src  []byte
+	}{
+		{name: "empty", src: []byte{}},
+		{name: "short", src: []byte("abc")},
+		{name: "repeated", src: bytes.Repeat([]byte("a"), 1000)},
+		{name: "log lines", src: []byte(strings.Repeat(`{"level":"info","msg":"request done","cost":12}`+"\n", 300))},
+		{name: "random", src: random},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := snappyEncode(tt.src)
+			decoded, err := snappyDecode(encoded)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.src, decoded)
+		})
+	}
+
+	// compressible input should be compressed indeed.
+	src := []byte(strings.Repeat("the same log line\n", 100))
+	assert.Less(t, len(snappyEncode(src)), len(src)/4)
+}